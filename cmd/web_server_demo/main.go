@@ -1,12 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/TheSeaGiraffe/web_server_demo/internal/controllers"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/export"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/filter"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/mailer"
 	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/moderation"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/password"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/scheduler"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/thumbnail"
 	"github.com/joho/godotenv"
 )
 
@@ -29,32 +41,271 @@ func main() {
 	}
 	jwtSecret := os.Getenv("JWT_SECRET")
 	polkaApiKey := os.Getenv("POLKA_API_KEY")
+	profanityListPath := os.Getenv("PROFANITY_LIST_PATH")
+	if profanityListPath == "" {
+		profanityListPath = filter.DefaultListPath
+	}
+	moderationSpec := os.Getenv("MODERATION_PIPELINE")
+	if moderationSpec == "" {
+		moderationSpec = moderation.DefaultPipelineSpec
+	}
+
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+	unverifiedRestrictions, err := controllers.ParseRestrictions(os.Getenv("UNVERIFIED_RESTRICTIONS"))
+	if err != nil {
+		log.Fatalf("Could not parse restrictions for unverified users: %s", err)
+	}
+
+	deletionGracePeriod := controllers.DefaultAccountDeletionGracePeriod
+	if gracePeriod := os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD"); gracePeriod != "" {
+		deletionGracePeriod, err = time.ParseDuration(gracePeriod)
+		if err != nil {
+			log.Fatalf("Invalid account deletion grace period: %s", err)
+		}
+	}
+
+	passwordPolicy := password.DefaultPolicy
+	if minLength := os.Getenv("PASSWORD_MIN_LENGTH"); minLength != "" {
+		passwordPolicy.MinLength, err = strconv.Atoi(minLength)
+		if err != nil {
+			log.Fatalf("Invalid minimum password length: %s", err)
+		}
+	}
+	if maxLength := os.Getenv("PASSWORD_MAX_LENGTH"); maxLength != "" {
+		passwordPolicy.MaxLength, err = strconv.Atoi(maxLength)
+		if err != nil {
+			log.Fatalf("Invalid maximum password length: %s", err)
+		}
+	}
+	passwordPolicy.Require, err = password.ParseClasses(os.Getenv("PASSWORD_REQUIRE"))
+	if err != nil {
+		log.Fatalf("Could not parse required password characters: %s", err)
+	}
+	breachListPath := os.Getenv("BREACHED_PASSWORDS_PATH")
+	if breachListPath == "" {
+		breachListPath = password.DefaultBreachListPath
+	}
+	passwordPolicy.Breached, err = password.LoadBreachList(breachListPath)
+	if err != nil {
+		log.Fatalf("Could not load breached password list: %s", err)
+	}
+
+	// New hashes use these settings and older ones are upgraded when users log in
+	passwordHasher := password.DefaultHasher
+	if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm != "" {
+		passwordHasher.Algorithm, err = password.ParseAlgorithm(algorithm)
+		if err != nil {
+			log.Fatalf("Could not set up password hashing: %s", err)
+		}
+	}
+	if bcryptCost := os.Getenv("BCRYPT_COST"); bcryptCost != "" {
+		passwordHasher.BcryptCost, err = strconv.Atoi(bcryptCost)
+		if err != nil {
+			log.Fatalf("Invalid bcrypt cost: %s", err)
+		}
+	}
+	if memory := os.Getenv("ARGON2_MEMORY"); memory != "" {
+		value, err := strconv.ParseUint(memory, 10, 32)
+		if err != nil {
+			log.Fatalf("Invalid argon2 memory: %s", err)
+		}
+		passwordHasher.Argon2.Memory = uint32(value)
+	}
+	if iterations := os.Getenv("ARGON2_ITERATIONS"); iterations != "" {
+		value, err := strconv.ParseUint(iterations, 10, 32)
+		if err != nil {
+			log.Fatalf("Invalid argon2 iterations: %s", err)
+		}
+		passwordHasher.Argon2.Iterations = uint32(value)
+	}
+	if parallelism := os.Getenv("ARGON2_PARALLELISM"); parallelism != "" {
+		value, err := strconv.ParseUint(parallelism, 10, 8)
+		if err != nil {
+			log.Fatalf("Invalid argon2 parallelism: %s", err)
+		}
+		passwordHasher.Argon2.Parallelism = uint8(value)
+	}
+	DB.SetPasswordHasher(passwordHasher)
+
+	// Users listed in ADMIN_EMAILS are made admins once they have verified their email
+	// address. The list is applied at startup and admins are never removed by it.
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		_, err := DB.SetAdmin(email)
+		if err != nil {
+			log.Printf("Could not make %s an admin: %s", email, err)
+		}
+	}
 
 	cfg := controllers.NewApiConfig(jwtSecret, polkaApiKey)
 
+	// Init mailer
+	// Without MAILER messages go to the outbox, which is only meant for local testing
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "Chirpy <no-reply@chirpy.local>"
+	}
+	var appMailer mailer.Mailer
+	switch mailerKind := os.Getenv("MAILER"); mailerKind {
+	case "smtp":
+		smtpPort, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			log.Fatalf("Invalid SMTP port: %s", err)
+		}
+		appMailer = mailer.SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     smtpPort,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     mailFrom,
+		}
+	case "", "outbox":
+		outboxDir := os.Getenv("OUTBOX_DIR")
+		if outboxDir == "" {
+			outboxDir = mailer.DefaultOutboxDir
+		}
+		if isServed(filepathRoot, outboxDir) {
+			log.Fatalf("Outbox directory '%s' can't be inside the served directory", outboxDir)
+		}
+		appMailer, err = mailer.NewOutboxMailer(outboxDir, mailFrom)
+		if err != nil {
+			log.Fatalf("Could not set up outbox: %s", err)
+		}
+		if mailerKind == "" {
+			log.Printf("MAILER isn't set, so emails are written to %s instead of being sent", outboxDir)
+		}
+	default:
+		log.Fatalf("Unknown mailer '%s'", mailerKind)
+	}
+
+	// Init background workers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	thumbnailCacheDir := os.Getenv("THUMBNAIL_CACHE_DIR")
+	if thumbnailCacheDir == "" {
+		thumbnailCacheDir = thumbnail.DefaultCacheDir
+	}
+	if isServed(filepathRoot, thumbnailCacheDir) {
+		log.Fatalf("Thumbnail cache directory '%s' can't be inside the served directory", thumbnailCacheDir)
+	}
+	thumbnails, err := thumbnail.NewService(filepathRoot, thumbnailCacheDir)
+	if err != nil {
+		log.Fatalf("Could not start thumbnail service: %s", err)
+	}
+	thumbnails.Start(ctx, 2)
+
+	exportDir := os.Getenv("EXPORT_DIR")
+	if exportDir == "" {
+		exportDir = export.DefaultDir
+	}
+	if isServed(filepathRoot, exportDir) {
+		log.Fatalf("Export directory '%s' can't be inside the served directory", exportDir)
+	}
+	exports, err := export.NewService(DB, filepathRoot, exportDir)
+	if err != nil {
+		log.Fatalf("Could not start export service: %s", err)
+	}
+	err = exports.Start(ctx, 1)
+	if err != nil {
+		log.Fatalf("Could not start export service: %s", err)
+	}
+
+	profanityFilter, err := filter.Load(profanityListPath)
+	if err != nil {
+		log.Fatalf("Could not load profanity filter: %s", err)
+	}
+	go profanityFilter.Watch(ctx, 10*time.Second)
+
+	moderationPipeline, err := moderation.ParsePipeline(moderationSpec, profanityFilter)
+	if err != nil {
+		log.Fatalf("Could not set up moderation pipeline: %s", err)
+	}
+	moderationPipeline.Watch(ctx, 10*time.Second)
+
+	chirpScheduler := scheduler.Scheduler{DB: DB, Interval: scheduler.DefaultInterval}
+	go chirpScheduler.Run(ctx)
+
 	// Setup the routes
 	application := controllers.Application{
-		DB:     DB,
-		Config: cfg,
+		DB:         DB,
+		Config:     cfg,
+		Moderation: moderationPipeline,
+		Thumbnails: thumbnails,
+		Mailer:     appMailer,
+		Exports:    exports,
+		BaseURL:    baseURL,
+
+		UnverifiedRestrictions:     unverifiedRestrictions,
+		AccountDeletionGracePeriod: deletionGracePeriod,
+		PasswordPolicy:             passwordPolicy,
 	}
 
 	fileServer := http.FileServer(http.Dir(filepathRoot))
 	mux := http.NewServeMux()
-	mux.Handle("/app/*", application.MiddlewareMetricsInc(http.StripPrefix("/app", fileServer)))
+	mux.Handle("/app/", application.MiddlewareMetricsInc(http.StripPrefix("/app", application.MiddlewareThumbnail(fileServer))))
 	mux.HandleFunc("GET /admin/metrics", application.AdminMetricsHandler)
 	mux.HandleFunc("GET /api/healthz", application.ReadinessHandler)
 	mux.HandleFunc("GET /api/reset", application.ResetHitsHandler)
-	mux.HandleFunc("POST /api/chirps", application.MiddlewareRequireUser(application.CreateChirpHandler))
+	mux.HandleFunc("POST /api/chirps", application.MiddlewareRequireVerified(controllers.RestrictChirp, application.CreateChirpHandler))
 	mux.HandleFunc("GET /api/chirps", application.GetChirpsHandler)
+	mux.HandleFunc("GET /api/chirps/scheduled", application.MiddlewareRequireUser(application.ListScheduledChirpsHandler))
+	mux.HandleFunc("DELETE /api/chirps/scheduled/{chirpID}", application.MiddlewareRequireUser(application.CancelScheduledChirpHandler))
+	mux.HandleFunc("GET /api/timeline", application.MiddlewareRequireUser(application.TimelineHandler))
 	mux.HandleFunc("GET /api/chirps/{chirpID}", application.GetSingleChirpHandler)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", application.MiddlewareRequireVerified(controllers.RestrictChirp, application.UpdateChirpHandler))
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", application.MiddlewareRequireUser(application.DeleteChirpHandler))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", application.MiddlewareRequireVerified(controllers.RestrictVote, application.CreatePollVoteHandler))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/reports", application.MiddlewareRequireVerified(controllers.RestrictReport, application.CreateReportHandler))
+	mux.HandleFunc("GET /admin/reports", application.MiddlewareRequireAdmin(application.ListReportsHandler))
+	mux.HandleFunc("POST /admin/reports/{reportID}/resolve", application.MiddlewareRequireAdmin(application.ResolveReportHandler))
+	mux.HandleFunc("GET /admin/moderation-log", application.MiddlewareRequireAdmin(application.ModerationLogHandler))
+	mux.HandleFunc("POST /admin/users/{userID}/unlock", application.MiddlewareRequireAdmin(application.UnlockUserLoginHandler))
+	mux.HandleFunc("POST /api/drafts", application.MiddlewareRequireUser(application.CreateDraftHandler))
+	mux.HandleFunc("GET /api/drafts", application.MiddlewareRequireUser(application.ListDraftsHandler))
+	mux.HandleFunc("GET /api/drafts/{draftID}", application.MiddlewareRequireUser(application.GetDraftHandler))
+	mux.HandleFunc("PUT /api/drafts/{draftID}", application.MiddlewareRequireUser(application.UpdateDraftHandler))
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", application.MiddlewareRequireUser(application.DeleteDraftHandler))
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", application.MiddlewareRequireVerified(controllers.RestrictChirp, application.PublishDraftHandler))
 	mux.HandleFunc("POST /api/users", application.CreateUserHandler)
-	mux.HandleFunc("PUT /api/users", application.MiddlewareRequireUser(application.UpdateUserHandler))
+	mux.HandleFunc("GET /api/users/me", application.MiddlewareRequireUser(application.GetCurrentUserHandler))
+	mux.HandleFunc("DELETE /api/users/me", application.MiddlewareRequireUser(application.DeleteCurrentUserHandler))
+	mux.HandleFunc("GET /api/users/me/export", application.MiddlewareRequireUser(application.ExportHandler))
+	mux.HandleFunc("GET /api/users/me/export/status", application.MiddlewareRequireUser(application.ExportStatusHandler))
+	mux.HandleFunc("PATCH /api/users/me", application.MiddlewareRequireUser(application.UpdateCurrentUserHandler))
+	mux.HandleFunc("GET /api/users/{user}", application.GetUserHandler)
+	mux.HandleFunc("POST /api/users/me/verification-email", application.MiddlewareRequireUser(application.ResendVerificationEmailHandler))
+	mux.HandleFunc("GET /api/verify-email", application.VerifyEmailHandler)
+	mux.HandleFunc("POST /api/users/me/2fa", application.MiddlewareRequireUser(application.EnrolTOTPHandler))
+	mux.HandleFunc("POST /api/users/me/2fa/confirm", application.MiddlewareRequireUser(application.ConfirmTOTPHandler))
+	mux.HandleFunc("DELETE /api/users/me/2fa", application.MiddlewareRequireUser(application.DisableTOTPHandler))
+	mux.HandleFunc("POST /api/users/{userID}/follow", application.MiddlewareRequireVerified(controllers.RestrictFollow, application.FollowUserHandler))
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", application.MiddlewareRequireUser(application.UnfollowUserHandler))
+	mux.HandleFunc("GET /api/users/{userID}/followers", application.ListFollowersHandler)
+	mux.HandleFunc("GET /api/users/{userID}/following", application.ListFollowingHandler)
+	mux.HandleFunc("POST /api/users/{userID}/block", application.MiddlewareRequireUser(application.BlockUserHandler))
+	mux.HandleFunc("DELETE /api/users/{userID}/block", application.MiddlewareRequireUser(application.UnblockUserHandler))
+	mux.HandleFunc("POST /api/users/{userID}/mute", application.MiddlewareRequireUser(application.MuteUserHandler))
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", application.MiddlewareRequireUser(application.UnmuteUserHandler))
+	mux.HandleFunc("GET /api/blocks", application.MiddlewareRequireUser(application.ListBlocksHandler))
+	mux.HandleFunc("GET /api/mutes", application.MiddlewareRequireUser(application.ListMutesHandler))
 	mux.HandleFunc("POST /api/login", application.LoginHandler)
+	mux.HandleFunc("POST /api/login/2fa", application.LoginTwoFactorHandler)
+	mux.HandleFunc("POST /api/password-reset", application.RequestPasswordResetHandler)
+	mux.HandleFunc("POST /api/password-reset/confirm", application.ConfirmPasswordResetHandler)
 	mux.HandleFunc("POST /api/refresh",
 		application.MiddlewareAuthenticateRefresh(application.MiddlewareRequireUser(application.RefreshAccessTokenHandler)))
 	mux.HandleFunc("POST /api/revoke",
 		application.MiddlewareAuthenticateRefresh(application.MiddlewareRequireUser(application.RevokeRefreshTokenHandler)))
+	mux.HandleFunc("GET /api/sessions", application.MiddlewareRequireUser(application.ListSessionsHandler))
+	mux.HandleFunc("DELETE /api/sessions", application.MiddlewareRequireUser(application.DeleteAllSessionsHandler))
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", application.MiddlewareRequireUser(application.DeleteSessionHandler))
 	mux.HandleFunc("POST /api/polka/webhooks", application.MiddlewareAuthenticatePolka(application.UpgradeToChirpyRedHandler))
 
 	// Setup and run server
@@ -65,3 +316,20 @@ func main() {
 	log.Printf("Starting sever on port %s...\n", port)
 	log.Fatal(srv.ListenAndServe())
 }
+
+// isServed reports whether dir is inside root, where the /app/ file server can read it
+func isServed(root, dir string) bool {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return true
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(absRoot, absDir)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
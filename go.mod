@@ -3,7 +3,10 @@ module github.com/TheSeaGiraffe/web_server_demo
 go 1.22.4
 
 require (
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	golang.org/x/crypto v0.26.0 // indirect
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.26.0
+	golang.org/x/text v0.18.0
 )
+
+require golang.org/x/sys v0.23.0 // indirect
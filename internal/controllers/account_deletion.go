@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// DefaultAccountDeletionGracePeriod is how long users have to change their mind after
+// asking for their account to be deleted
+const DefaultAccountDeletionGracePeriod = 14 * 24 * time.Hour
+
+// DeleteCurrentUserHandler deletes the signed-in user's account once the grace period
+// is over. The user is logged out, and logging in again before then cancels the
+// deletion. With no grace period the account is deleted straight away.
+func (app *Application) DeleteCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON from the response body
+	var input struct {
+		Password string `json:"password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	user := app.contextGetUser(r)
+	err = app.DB.CheckPassword(*user, input.Password)
+	if err != nil {
+		app.errorResponse(w, http.StatusUnauthorized, "Password is incorrect")
+		return
+	}
+
+	if app.AccountDeletionGracePeriod <= 0 {
+		err = app.DB.DeleteUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	updated, err := app.DB.ScheduleUserDeletion(user.ID, time.Now().Add(app.AccountDeletionGracePeriod))
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{
+		"message":               "Account will be deleted unless you log in again before then",
+		"deletion_scheduled_at": updated.DeletionScheduledAt,
+	}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
@@ -3,13 +3,37 @@ package controllers
 import (
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/TheSeaGiraffe/web_server_demo/internal/export"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/mailer"
 	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/moderation"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/password"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/thumbnail"
 )
 
 type Application struct {
-	Config ApiConfig
-	DB     *models.DB
+	Config     ApiConfig
+	DB         *models.DB
+	Moderation *moderation.Pipeline
+	Thumbnails *thumbnail.Service
+	Mailer     mailer.Mailer
+	Exports    *export.Service
+
+	// BaseURL is the public address of the server used to build links in emails
+	BaseURL string
+
+	// UnverifiedRestrictions are the actions users can't take until they have verified
+	// their email address
+	UnverifiedRestrictions map[Restriction]bool
+
+	// AccountDeletionGracePeriod is how long a deleted account can still be recovered
+	// by logging in. Accounts are deleted straight away if it is zero.
+	AccountDeletionGracePeriod time.Duration
+
+	// PasswordPolicy is checked whenever a user chooses a new password
+	PasswordPolicy password.Policy
 }
 
 func (app *Application) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+// blockResponse is a user that has been blocked or muted
+type blockResponse struct {
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (app *Application) BlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	blockedID, ok := app.readUserIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	_, err := app.DB.CreateBlock(user.ID, blockedID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUserNotExist):
+			app.errorResponse(w, http.StatusNotFound, "Could not find user")
+		case errors.Is(err, models.ErrBlockSelf):
+			app.errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, models.ErrAlreadyBlocked):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *Application) UnblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	blockedID, ok := app.readUserIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	err := app.DB.DeleteBlock(user.ID, blockedID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotBlocked) {
+			app.errorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *Application) MuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	mutedID, ok := app.readUserIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	_, err := app.DB.CreateMute(user.ID, mutedID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUserNotExist):
+			app.errorResponse(w, http.StatusNotFound, "Could not find user")
+		case errors.Is(err, models.ErrBlockSelf):
+			app.errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, models.ErrAlreadyMuted):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *Application) UnmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	mutedID, ok := app.readUserIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	err := app.DB.DeleteMute(user.ID, mutedID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotMuted) {
+			app.errorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *Application) ListBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	blocks, err := app.DB.GetBlocks(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	users := make([]blockResponse, 0, len(blocks))
+	for _, b := range blocks {
+		users = append(users, blockResponse{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"users": users}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) ListMutesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	mutes, err := app.DB.GetMutes(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	users := make([]blockResponse, 0, len(mutes))
+	for _, m := range mutes {
+		users = append(users, blockResponse{UserID: m.MutedID, CreatedAt: m.CreatedAt})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"users": users}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
@@ -3,65 +3,364 @@ package controllers
 import (
 	"cmp"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/moderation"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/validator"
 )
 
-const replacementString = "****"
+// moderateChirp runs the body of the chirp through the moderation pipeline and records
+// the outcome on the chirp. It reports false if the chirp was rejected. Decisions are
+// added to the ones already on the chirp, and a held chirp stays held until an admin
+// deals with it even if an edit passes moderation.
+func (app *Application) moderateChirp(chirp *models.Chirp) (ok bool, reason string) {
+	result := app.Moderation.Run(chirp.Body)
+	if result.Rejected {
+		return false, result.RejectReason()
+	}
+
+	chirp.Body = result.Body
+	applyModeration(chirp, result)
+	return true, ""
+}
+
+// applyModeration records the outcome of moderating part of a chirp on the chirp. A
+// chirp that is already held stays held.
+func applyModeration(chirp *models.Chirp, result moderation.Result) {
+	if chirp.Status != models.ChirpHeld {
+		chirp.Status = result.Status
+		if chirp.Status == models.ChirpPublished && chirp.PublishAt != nil && chirp.PublishAt.After(time.Now()) {
+			chirp.Status = models.ChirpScheduled
+		}
+	}
+	chirp.Flagged = chirp.Flagged || result.Flagged
+	chirp.Moderation = append(chirp.Moderation, result.Decisions...)
+}
+
+// queueHeldChirp adds a report for chirps held by the moderation pipeline so that they
+// show up in the moderation queue
+func (app *Application) queueHeldChirp(chirp models.Chirp) {
+	if chirp.Status != models.ChirpHeld {
+		return
+	}
+
+	_, err := app.DB.CreateReport(chirp.ID, 0, models.ReportModerationHold, "")
+	if err != nil && !errors.Is(err, models.ErrReportDuplicate) {
+		log.Printf("Could not queue held chirp %d for review: %s", chirp.ID, err)
+	}
+}
+
+// canViewChirp reports whether user (which may be nil) is allowed to see chirp
+func (app *Application) canViewChirp(user *models.User, chirp models.Chirp) bool {
+	switch {
+	case chirp.Status == models.ChirpScheduled:
+		// Scheduled chirps are only listed through the scheduled chirps endpoint
+		return false
+	case !chirp.IsVisibleTo(user):
+		return false
+	case chirp.IsPublished():
+		return true
+	default:
+		return user != nil && user.ID == chirp.AuthorID
+	}
+}
+
+// chirpViewer returns a function that reports whether user (which may be nil) can see a
+// chirp. On top of canViewChirp it hides chirps by users that the user has blocked or
+// muted and by users that have blocked them.
+func (app *Application) chirpViewer(user *models.User) (func(models.Chirp) bool, error) {
+	if user == nil {
+		return func(chirp models.Chirp) bool {
+			return app.canViewChirp(nil, chirp)
+		}, nil
+	}
 
-var badWords = map[string]struct{}{
-	"kerfuffle": {},
-	"sharbert":  {},
-	"fornax":    {},
+	hidden, err := app.DB.GetHiddenAuthors(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(chirp models.Chirp) bool {
+		if _, ok := hidden[chirp.AuthorID]; ok {
+			return false
+		}
+		return app.canViewChirp(user, chirp)
+	}, nil
 }
 
-func replaceBadWords(chirp string) string {
-	chirpSplit := strings.Fields(chirp)
-	for i, word := range chirpSplit {
-		_, ok := badWords[strings.ToLower(word)]
-		if ok {
-			chirpSplit[i] = replacementString
+// chirpResponse is how chirps are written out to clients. Moderation details are left
+// out since only admins can see them, through the report listing.
+type chirpResponse struct {
+	models.Chirp
+	Poll *pollResponse `json:"poll,omitempty"`
+}
+
+// chirpResponses attaches the poll results that user (which may be nil) is allowed to
+// see to each chirp
+func (app *Application) chirpResponses(user *models.User, chirps []models.Chirp) ([]chirpResponse, error) {
+	userID := 0
+	if user != nil {
+		userID = user.ID
+	}
+
+	polls, err := app.DB.GetPollSummaries(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep nil as nil so that an empty listing looks the same as it always has
+	var responses []chirpResponse
+	if chirps != nil {
+		responses = make([]chirpResponse, 0, len(chirps))
+	}
+	for _, chirp := range chirps {
+		resp := chirpResponse{Chirp: chirp.WithoutModeration()}
+		if summary, ok := polls[chirp.ID]; ok {
+			resp.Poll = newPollResponse(summary)
 		}
+		responses = append(responses, resp)
 	}
-	return strings.Join(chirpSplit, " ")
+
+	return responses, nil
+}
+
+func (app *Application) chirpResponse(user *models.User, chirp models.Chirp) (chirpResponse, error) {
+	responses, err := app.chirpResponses(user, []models.Chirp{chirp})
+	if err != nil {
+		return chirpResponse{}, err
+	}
+	return responses[0], nil
+}
+
+func (app *Application) chirpRejectedResponse(w http.ResponseWriter, reason string) {
+	app.errorResponse(w, http.StatusUnprocessableEntity, envelope{
+		"message": "Chirp was rejected by moderation",
+		"reason":  reason,
+	})
+}
+
+type chirpInput struct {
+	Body       string                 `json:"body"`
+	Media      []string               `json:"media"`
+	Visibility models.ChirpVisibility `json:"visibility"`
+	PublishAt  *time.Time             `json:"publish_at"`
+	Poll       *pollInput             `json:"poll"`
+}
+
+// validVisibility reports whether visibility is empty (the default) or one of the
+// known visibility levels. An error response is written if it isn't.
+func (app *Application) validVisibility(w http.ResponseWriter, visibility models.ChirpVisibility) bool {
+	if visibility != "" && !slices.Contains(models.ChirpVisibilities, visibility) {
+		app.errorResponse(w, http.StatusBadRequest, envelope{
+			"message":      "Invalid visibility",
+			"visibilities": models.ChirpVisibilities,
+		})
+		return false
+	}
+	return true
+}
+
+// prepareChirp checks input against the limits for the user's tier and runs it through
+// moderation. Every path that creates chirps goes through here. If the chirp can't be
+// created an error response is written and ok is false. The rate limit is checked
+// when the chirp is saved.
+func (app *Application) prepareChirp(w http.ResponseWriter, r *http.Request, input *chirpInput) (chirp models.Chirp, ok bool) {
+	// Check the chirp against the limits for the user's tier
+	user := app.contextGetUser(r)
+	policy := user.Policy()
+	body, err := validator.ChirpBody(input.Body, policy.MaxChirpLength)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, err.Error())
+		return models.Chirp{}, false
+	}
+	if len(input.Media) > policy.MaxMediaPerChirp {
+		app.errorResponse(w, http.StatusBadRequest, "Chirp has too many media attachments")
+		return models.Chirp{}, false
+	}
+	input.Media, err = validator.MediaList(input.Media)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, err.Error())
+		return models.Chirp{}, false
+	}
+	if !app.validVisibility(w, input.Visibility) {
+		return models.Chirp{}, false
+	}
+	if input.PublishAt != nil {
+		publishIn := time.Until(*input.PublishAt)
+		if publishIn <= 0 {
+			app.errorResponse(w, http.StatusBadRequest, "publish_at must be in the future")
+			return models.Chirp{}, false
+		}
+		if publishIn > maxScheduleAhead {
+			app.errorResponse(w, http.StatusBadRequest, "Chirps can't be scheduled more than a year ahead")
+			return models.Chirp{}, false
+		}
+		publishAt := input.PublishAt.UTC()
+		input.PublishAt = &publishAt
+	}
+	if input.Poll != nil && !app.validatePoll(w, input.Poll, input.PublishAt) {
+		return models.Chirp{}, false
+	}
+
+	// Run the chirp through moderation
+	chirp = models.Chirp{
+		Body:       body,
+		AuthorID:   user.ID,
+		Media:      input.Media,
+		Visibility: input.Visibility,
+		PublishAt:  input.PublishAt,
+	}
+	if allowed, reason := app.moderateChirp(&chirp); !allowed {
+		app.chirpRejectedResponse(w, reason)
+		return models.Chirp{}, false
+	}
+	if input.Poll != nil {
+		if allowed, reason := app.moderatePoll(&chirp, input.Poll); !allowed {
+			app.chirpRejectedResponse(w, reason)
+			return models.Chirp{}, false
+		}
+	}
+
+	return chirp, true
 }
 
 func (app *Application) CreateChirpHandler(w http.ResponseWriter, r *http.Request) {
 	// Decode the JSON from the response body
-	var input struct {
-		Body string `json:"body"`
-	}
+	var input chirpInput
 	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
 		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
 		return
 	}
 
-	// Check that response is <= 140 chars
-	const maxChirpLength = 140
-	if len(input.Body) > maxChirpLength {
-		app.errorResponse(w, http.StatusBadRequest, "Chirp is too long")
+	chirp, ok := app.prepareChirp(w, r, &input)
+	if !ok {
 		return
 	}
 
-	// Remove profanity and get user ID
-	userID := (app.contextGetUser(r)).ID
-	cleanedBody := replaceBadWords(input.Body)
-	chirp, err := app.DB.CreateChirp(cleanedBody, userID)
+	if input.Poll != nil {
+		chirp, err = app.DB.CreateChirpWithPoll(chirp, input.Poll.Options, input.Poll.ClosesAt)
+	} else {
+		chirp, err = app.DB.CreateChirp(chirp)
+	}
 	if err != nil {
+		if errors.Is(err, models.ErrChirpRateLimited) {
+			app.rateLimitExceededResponse(w, r)
+			return
+		}
 		app.errorResponse(w, http.StatusInternalServerError, "Couldn't create chirp")
 		return
 	}
+	app.queueHeldChirp(chirp)
 
 	// Response is valid
-	// output := map[string]string{"cleaned_body": cleanedBody}
-	// err = writeJSON(w, http.StatusOK, output, nil)
-	err = app.writeJSON(w, http.StatusCreated, chirp, nil)
+	output, err := app.chirpResponse(app.contextGetUser(r), chirp)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, output, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) UpdateChirpHandler(w http.ResponseWriter, r *http.Request) {
+	// Get chirp ID from URL path
+	chirpIDStr := r.PathValue("chirpID")
+	chirpID, err := strconv.Atoi(chirpIDStr)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	// Get the chirp with the specified ID
+	chirp, err := app.DB.GetChirpByID(chirpID)
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Chirp with that ID doesn't exist")
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if chirp.AuthorID != user.ID {
+		// Don't reveal that a chirp the user can't see exists
+		canView, err := app.chirpViewer(user)
+		if err != nil {
+			app.serverErrorResponse(w, r)
+			return
+		}
+		if !canView(chirp) {
+			app.errorResponse(w, http.StatusNotFound, "Chirp with that ID doesn't exist")
+			return
+		}
+		app.errorResponse(w, http.StatusForbidden, "User is not allowed to access this resource")
+		return
+	}
+
+	// Chirps hidden by a moderator can't be edited back into view
+	if chirp.Status == models.ChirpHidden {
+		app.errorResponse(w, http.StatusForbidden, "This chirp has been hidden by a moderator")
+		return
+	}
+
+	// Check that the chirp is still within the edit window for the user's tier
+	policy := user.Policy()
+	err = policy.CheckEdit(chirp.CreatedAt, time.Now())
+	if err != nil {
+		app.errorResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	// Decode the JSON from the response body
+	var input struct {
+		Body       string                 `json:"body"`
+		Visibility models.ChirpVisibility `json:"visibility"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	if !app.validVisibility(w, input.Visibility) {
+		return
+	}
+
+	body, err := validator.ChirpBody(input.Body, policy.MaxChirpLength)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Run the edit through moderation and save it
+	chirp.Body = body
+	if input.Visibility != "" {
+		chirp.Visibility = input.Visibility
+	}
+	if ok, reason := app.moderateChirp(&chirp); !ok {
+		app.chirpRejectedResponse(w, reason)
+		return
+	}
+
+	chirp, err = app.DB.UpdateChirp(chirp)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	app.queueHeldChirp(chirp)
+
+	output, err := app.chirpResponse(user, chirp)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, output, nil)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
 	}
@@ -83,6 +382,17 @@ func (app *Application) GetChirpsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Drop chirps the user isn't allowed to see
+	user := app.contextGetUser(r)
+	canView, err := app.chirpViewer(user)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	chirps = slices.DeleteFunc(chirps, func(chirp models.Chirp) bool {
+		return !canView(chirp)
+	})
+
 	// Sort the chirps and then filter by "author_id" if it is provided
 	var chirpsFiltered []models.Chirp
 	if len(chirps) > 0 {
@@ -116,7 +426,12 @@ func (app *Application) GetChirpsHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Return the chirps in a json response
-	err = app.writeJSON(w, http.StatusOK, chirpsFiltered, nil)
+	output, err := app.chirpResponses(user, chirpsFiltered)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, output, nil)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
 	}
@@ -132,13 +447,24 @@ func (app *Application) GetSingleChirpHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	// Get the chirp with the specified ID
-	chirp, err := app.DB.GetChirpByID(chirpID)
+	user := app.contextGetUser(r)
+	canView, err := app.chirpViewer(user)
 	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	chirp, err := app.DB.GetChirpByID(chirpID)
+	if err != nil || !canView(chirp) {
 		app.errorResponse(w, http.StatusNotFound, "Chirp with that ID doesn't exist")
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, chirp, nil)
+	output, err := app.chirpResponse(user, chirp)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, output, nil)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
 	}
@@ -160,8 +486,18 @@ func (app *Application) DeleteChirpHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	userID := (app.contextGetUser(r)).ID
-	if chirp.AuthorID != userID {
+	user := app.contextGetUser(r)
+	if chirp.AuthorID != user.ID {
+		// Don't reveal that a chirp the user can't see exists
+		canView, err := app.chirpViewer(user)
+		if err != nil {
+			app.serverErrorResponse(w, r)
+			return
+		}
+		if !canView(chirp) {
+			app.errorResponse(w, http.StatusNotFound, "Chirp with that ID doesn't exist")
+			return
+		}
 		app.errorResponse(w, http.StatusForbidden, "User is not allowed to access this resource")
 		return
 	}
@@ -1,8 +1,18 @@
 package controllers
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
 
-func TestReplaceBadWords(t *testing.T) {
+	"github.com/TheSeaGiraffe/web_server_demo/internal/filter"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/moderation"
+)
+
+func TestModerateChirp(t *testing.T) {
 	cases := []struct {
 		name  string
 		chirp string
@@ -26,7 +36,12 @@ func TestReplaceBadWords(t *testing.T) {
 		{
 			name:  "Test sharbert with exclamation point",
 			chirp: "Sharbert! My bad man",
-			want:  "Sharbert! My bad man",
+			want:  "****! My bad man",
+		},
+		{
+			name:  "Test whitespace is preserved",
+			chirp: "Kerfuffle,  really?\n\tNo  way",
+			want:  "****,  really?\n\tNo  way",
 		},
 		{
 			name:  "Test capital fornax",
@@ -35,11 +50,158 @@ func TestReplaceBadWords(t *testing.T) {
 		},
 	}
 
+	profanity := filter.New(filter.DefaultList())
+	app := Application{
+		Moderation: moderation.NewPipeline(moderation.MaskStage{Name: "mask", Filter: profanity}),
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			chirp := models.Chirp{Body: c.chirp}
+			ok, _ := app.moderateChirp(&chirp)
+			if !ok {
+				t.Fatalf("Chirp was rejected")
+			}
+			if chirp.Body != c.want {
+				t.Errorf("Expected '%v'\ngot '%v'", c.want, chirp.Body)
+			}
+		})
+	}
+}
+
+func TestModerateEditedChirp(t *testing.T) {
+	holdList := filter.New(filter.DefaultList())
+	app := Application{
+		Moderation: moderation.NewPipeline(moderation.KeywordStage{Name: "hold", Action: models.ModerationHold, Filter: holdList}),
+	}
+
+	cases := []struct {
+		name       string
+		status     models.ChirpStatus
+		body       string
+		wantStatus models.ChirpStatus
+	}{
+		{"Test held chirp stays held after clean edit", models.ChirpHeld, "Nothing to see here", models.ChirpHeld},
+		{"Test published chirp is held after edit", models.ChirpPublished, "Get this sharbert outta here", models.ChirpHeld},
+		{"Test published chirp stays published after clean edit", models.ChirpPublished, "Nothing to see here", models.ChirpPublished},
+	}
+
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			got := replaceBadWords(c.chirp)
-			if got != c.want {
-				t.Errorf("Expected '%v'\ngot '%v'", c.want, got)
+			earlier := models.ModerationDecision{Stage: "hold", Action: models.ModerationHold}
+			chirp := models.Chirp{Body: c.body, Status: c.status, Moderation: []models.ModerationDecision{earlier}}
+			ok, _ := app.moderateChirp(&chirp)
+			if !ok {
+				t.Fatalf("Chirp was rejected")
+			}
+			if chirp.Status != c.wantStatus {
+				t.Errorf("Expected '%v'\ngot '%v'", c.wantStatus, chirp.Status)
+			}
+			if len(chirp.Moderation) != 2 || chirp.Moderation[0] != earlier {
+				t.Errorf("Expected the new decision to be added to the earlier one\ngot %+v", chirp.Moderation)
+			}
+		})
+	}
+}
+
+func TestPrepareChirpTierLimits(t *testing.T) {
+	app := Application{Moderation: moderation.NewPipeline()}
+	free := models.TierPolicies[models.TierFree]
+	red := models.TierPolicies[models.TierChirpyRed]
+	media := func(n int) []string {
+		media := make([]string, n)
+		for i := range media {
+			media[i] = "/app/assets/logo.png"
+		}
+		return media
+	}
+
+	cases := []struct {
+		name   string
+		user   models.User
+		input  chirpInput
+		wantOK bool
+	}{
+		{"Test free tier longest chirp", models.User{}, chirpInput{Body: strings.Repeat("a", free.MaxChirpLength)}, true},
+		{"Test free tier chirp too long", models.User{}, chirpInput{Body: strings.Repeat("a", free.MaxChirpLength+1)}, false},
+		{"Test free tier most media", models.User{}, chirpInput{Body: "a", Media: media(free.MaxMediaPerChirp)}, true},
+		{"Test free tier too many media", models.User{}, chirpInput{Body: "a", Media: media(free.MaxMediaPerChirp + 1)}, false},
+		{"Test Chirpy Red longest chirp", models.User{IsChirpyRed: true}, chirpInput{Body: strings.Repeat("a", red.MaxChirpLength)}, true},
+		{"Test Chirpy Red chirp too long", models.User{IsChirpyRed: true}, chirpInput{Body: strings.Repeat("a", red.MaxChirpLength+1)}, false},
+		{"Test Chirpy Red most media", models.User{IsChirpyRed: true}, chirpInput{Body: "a", Media: media(red.MaxMediaPerChirp)}, true},
+		{"Test Chirpy Red too many media", models.User{IsChirpyRed: true}, chirpInput{Body: "a", Media: media(red.MaxMediaPerChirp + 1)}, false},
+		{"Test media outside the media directory", models.User{IsChirpyRed: true}, chirpInput{Body: "a", Media: []string{"/app/.env"}}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := app.contextSetUser(httptest.NewRequest(http.MethodPost, "/api/chirps", nil), &c.user)
+			_, ok := app.prepareChirp(w, r, &c.input)
+			if ok != c.wantOK {
+				t.Errorf("Expected ok to be %v\ngot %v: %s", c.wantOK, ok, w.Body)
+			}
+			if !ok && w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d\ngot %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestChirpVisibilityResponses(t *testing.T) {
+	app := newTestApp(t)
+	db := app.DB
+
+	author := &models.User{ID: 1, IsChirpyRed: true}
+	other := &models.User{ID: 2, IsChirpyRed: true}
+	handlers := map[string]http.HandlerFunc{
+		http.MethodGet:    app.GetSingleChirpHandler,
+		http.MethodPut:    app.UpdateChirpHandler,
+		http.MethodDelete: app.DeleteChirpHandler,
+	}
+
+	// Users who can't see a chirp get a 404 so that they can't tell that it exists
+	cases := []struct {
+		name       string
+		visibility models.ChirpVisibility
+		method     string
+		viewer     *models.User
+		wantStatus int
+	}{
+		{"Test get public chirp anonymous", models.VisibilityPublic, http.MethodGet, nil, http.StatusOK},
+		{"Test get public chirp signed in", models.VisibilityPublic, http.MethodGet, other, http.StatusOK},
+		{"Test get public chirp author", models.VisibilityPublic, http.MethodGet, author, http.StatusOK},
+		{"Test get signed-in chirp anonymous", models.VisibilitySignedIn, http.MethodGet, nil, http.StatusNotFound},
+		{"Test get signed-in chirp signed in", models.VisibilitySignedIn, http.MethodGet, other, http.StatusOK},
+		{"Test get signed-in chirp author", models.VisibilitySignedIn, http.MethodGet, author, http.StatusOK},
+		{"Test get author-only chirp anonymous", models.VisibilityAuthorOnly, http.MethodGet, nil, http.StatusNotFound},
+		{"Test get author-only chirp signed in", models.VisibilityAuthorOnly, http.MethodGet, other, http.StatusNotFound},
+		{"Test get author-only chirp author", models.VisibilityAuthorOnly, http.MethodGet, author, http.StatusOK},
+		{"Test update public chirp signed in", models.VisibilityPublic, http.MethodPut, other, http.StatusForbidden},
+		{"Test update signed-in chirp signed in", models.VisibilitySignedIn, http.MethodPut, other, http.StatusForbidden},
+		{"Test update author-only chirp signed in", models.VisibilityAuthorOnly, http.MethodPut, other, http.StatusNotFound},
+		{"Test update author-only chirp author", models.VisibilityAuthorOnly, http.MethodPut, author, http.StatusOK},
+		{"Test delete public chirp signed in", models.VisibilityPublic, http.MethodDelete, other, http.StatusForbidden},
+		{"Test delete signed-in chirp signed in", models.VisibilitySignedIn, http.MethodDelete, other, http.StatusForbidden},
+		{"Test delete author-only chirp signed in", models.VisibilityAuthorOnly, http.MethodDelete, other, http.StatusNotFound},
+		{"Test delete author-only chirp author", models.VisibilityAuthorOnly, http.MethodDelete, author, http.StatusNoContent},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			chirp, err := db.CreateChirp(models.Chirp{Body: "chirp", AuthorID: author.ID, Visibility: c.visibility})
+			if err != nil {
+				t.Fatalf("could not create chirp: %v", err)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(c.method, "/api/chirps/"+strconv.Itoa(chirp.ID), strings.NewReader(`{"body": "edited"}`))
+			r.SetPathValue("chirpID", strconv.Itoa(chirp.ID))
+			if c.viewer != nil {
+				r = app.contextSetUser(r, c.viewer)
+			}
+			handlers[c.method](w, r)
+			if w.Code != c.wantStatus {
+				t.Errorf("Expected status %d\ngot %d: %s", c.wantStatus, w.Code, w.Body)
 			}
 		})
 	}
@@ -9,7 +9,10 @@ import (
 
 type contextKey string
 
-const userContextKey contextKey = "user"
+const (
+	userContextKey    contextKey = "user"
+	sessionContextKey contextKey = "session"
+)
 
 func (app *Application) contextSetUser(r *http.Request, user *models.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -24,3 +27,20 @@ func (app *Application) contextGetUser(r *http.Request) *models.User {
 
 	return user
 }
+
+// contextSetSessionID records which session (refresh token) the request was made with
+func (app *Application) contextSetSessionID(r *http.Request, sessionID int) *http.Request {
+	ctx := context.WithValue(r.Context(), sessionContextKey, sessionID)
+	return r.WithContext(ctx)
+}
+
+// contextGetSessionID returns the ID of the session the request was made with, or 0 if
+// it isn't known
+func (app *Application) contextGetSessionID(r *http.Request) int {
+	sessionID, ok := r.Context().Value(sessionContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return sessionID
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/validator"
+)
+
+// Drafts only need to fit the limits of a chirp once they are published. This just
+// keeps them from growing without bound.
+const (
+	maxDraftLength = 5000
+	maxDraftMedia  = 10
+)
+
+// validateDraft normalizes the draft body and media and writes an error response if the
+// draft is unreasonably large or its media is invalid
+func (app *Application) validateDraft(w http.ResponseWriter, input *chirpInput) bool {
+	input.Body = validator.NormalizeText(input.Body)
+	if validator.CharCount(input.Body) > maxDraftLength {
+		app.errorResponse(w, http.StatusBadRequest, "Draft is too long")
+		return false
+	}
+	if len(input.Media) > maxDraftMedia {
+		app.errorResponse(w, http.StatusBadRequest, "Draft has too many media attachments")
+		return false
+	}
+	media, err := validator.MediaList(input.Media)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	input.Media = media
+	if input.Poll != nil {
+		app.errorResponse(w, http.StatusBadRequest, "Drafts can't have polls")
+		return false
+	}
+	if !app.validVisibility(w, input.Visibility) {
+		return false
+	}
+	return true
+}
+
+// getDraftFromPath looks up the draft named in the URL path for the current user. If it
+// can't be found an error response is written and ok is false.
+func (app *Application) getDraftFromPath(w http.ResponseWriter, r *http.Request) (draft models.Draft, ok bool) {
+	draftID, err := strconv.Atoi(r.PathValue("draftID"))
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "Invalid draft ID")
+		return models.Draft{}, false
+	}
+
+	user := app.contextGetUser(r)
+	draft, err = app.DB.GetDraft(draftID, user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrDraftNotExist) {
+			app.errorResponse(w, http.StatusNotFound, "Draft with that ID doesn't exist")
+			return models.Draft{}, false
+		}
+		app.serverErrorResponse(w, r)
+		return models.Draft{}, false
+	}
+
+	return draft, true
+}
+
+func (app *Application) CreateDraftHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON from the response body
+	var input chirpInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	if !app.validateDraft(w, &input) {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	draft, err := app.DB.CreateDraft(models.Draft{
+		AuthorID:   user.ID,
+		Body:       input.Body,
+		Media:      input.Media,
+		Visibility: input.Visibility,
+		PublishAt:  input.PublishAt,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrTooManyDrafts) {
+			app.errorResponse(w, http.StatusConflict, "You have too many drafts")
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, draft, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) ListDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	drafts, err := app.DB.GetDraftsByAuthor(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, drafts, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) GetDraftHandler(w http.ResponseWriter, r *http.Request) {
+	draft, ok := app.getDraftFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, draft, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) UpdateDraftHandler(w http.ResponseWriter, r *http.Request) {
+	draft, ok := app.getDraftFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// Decode the JSON from the response body
+	var input chirpInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+	if !app.validateDraft(w, &input) {
+		return
+	}
+
+	draft.Body = input.Body
+	draft.Media = input.Media
+	draft.Visibility = input.Visibility
+	draft.PublishAt = input.PublishAt
+	draft, err = app.DB.UpdateDraft(draft)
+	if err != nil {
+		if errors.Is(err, models.ErrDraftNotExist) {
+			app.errorResponse(w, http.StatusNotFound, "Draft with that ID doesn't exist")
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, draft, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) DeleteDraftHandler(w http.ResponseWriter, r *http.Request) {
+	draft, ok := app.getDraftFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.DB.DeleteDraft(draft.ID, draft.AuthorID)
+	if err != nil {
+		if errors.Is(err, models.ErrDraftNotExist) {
+			app.errorResponse(w, http.StatusNotFound, "Draft with that ID doesn't exist")
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PublishDraftHandler turns a draft into a chirp. The draft goes through exactly the
+// same checks and moderation as a chirp created through CreateChirpHandler.
+func (app *Application) PublishDraftHandler(w http.ResponseWriter, r *http.Request) {
+	draft, ok := app.getDraftFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	chirp, ok := app.prepareChirp(w, r, &chirpInput{
+		Body:       draft.Body,
+		Media:      draft.Media,
+		Visibility: draft.Visibility,
+		PublishAt:  draft.PublishAt,
+	})
+	if !ok {
+		return
+	}
+
+	chirp, err := app.DB.PublishDraft(draft.ID, chirp)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDraftNotExist):
+			app.errorResponse(w, http.StatusNotFound, "Draft with that ID doesn't exist")
+		case errors.Is(err, models.ErrChirpRateLimited):
+			app.rateLimitExceededResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+	app.queueHeldChirp(chirp)
+
+	output, err := app.chirpResponse(app.contextGetUser(r), chirp)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, output, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
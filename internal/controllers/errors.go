@@ -1,6 +1,11 @@
 package controllers
 
-import "net/http"
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
 
 func (app *Application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid authentication credentials"
@@ -23,3 +28,35 @@ func (app *Application) authenticationRequiredResponse(w http.ResponseWriter, r
 	message := "you must be authenticated to access this resource"
 	app.errorResponse(w, http.StatusUnauthorized, message)
 }
+
+func (app *Application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "rate limit exceeded"
+	app.errorResponse(w, http.StatusTooManyRequests, message)
+}
+
+func (app *Application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, http.StatusForbidden, message)
+}
+
+func (app *Application) accountSuspendedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account has been suspended"
+	app.errorResponse(w, http.StatusForbidden, message)
+}
+
+func (app *Application) emailNotVerifiedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you must verify your email address before you can do this"
+	app.errorResponse(w, http.StatusForbidden, message)
+}
+
+func (app *Application) accountPendingDeletionResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account is scheduled for deletion, log in again to cancel it"
+	app.errorResponse(w, http.StatusForbidden, message)
+}
+
+func (app *Application) loginThrottledResponse(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+
+	message := "too many failed login attempts, try again later"
+	app.errorResponse(w, http.StatusTooManyRequests, message)
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/export"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+const exportStatusPath = "/api/users/me/export/status"
+
+// exportResponse describes an export without giving away where it is stored
+type exportResponse struct {
+	ID          int                 `json:"id"`
+	Status      models.ExportStatus `json:"status"`
+	Error       string              `json:"error,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time          `json:"expires_at,omitempty"`
+}
+
+func newExportResponse(export models.DataExport) exportResponse {
+	return exportResponse{
+		ID:          export.ID,
+		Status:      export.Status,
+		Error:       export.Error,
+		CreatedAt:   export.CreatedAt,
+		CompletedAt: export.CompletedAt,
+		ExpiresAt:   export.ExpiresAt,
+	}
+}
+
+// ExportHandler downloads an archive of everything stored about the signed-in user. The
+// archive is built in the background, so until it is ready the response is a 202 with
+// the export's status instead.
+func (app *Application) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	dataExport, err := app.Exports.Request(user.ID)
+	if err != nil {
+		if errors.Is(err, export.ErrBusy) {
+			w.Header().Set("Retry-After", "60")
+			app.errorResponse(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	if dataExport.Status != models.ExportReady {
+		err = app.writeJSON(w, http.StatusAccepted, envelope{
+			"export":     newExportResponse(dataExport),
+			"status_url": exportStatusPath,
+		}, http.Header{"Location": {exportStatusPath}})
+		if err != nil {
+			log.Printf("Error marshalling JSON: %s", err)
+		}
+		return
+	}
+
+	file, err := app.Exports.Open(dataExport)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%d.zip"`, user.ID))
+	http.ServeContent(w, r, "", *dataExport.CompletedAt, file)
+}
+
+func (app *Application) ExportStatusHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	export, err := app.DB.GetLatestDataExport(user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrExportNotExist) {
+			app.errorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"export": newExportResponse(export)}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
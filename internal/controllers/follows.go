@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+type followResponse struct {
+	UserID     int       `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// readUserIDParam reads the user ID from the URL path. If it isn't valid an error
+// response is written and ok is false.
+func (app *Application) readUserIDParam(w http.ResponseWriter, r *http.Request) (id int, ok bool) {
+	id, err := strconv.Atoi(r.PathValue("userID"))
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return 0, false
+	}
+	return id, true
+}
+
+func (app *Application) FollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	followeeID, ok := app.readUserIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	_, err := app.DB.CreateFollow(user.ID, followeeID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUserNotExist):
+			app.errorResponse(w, http.StatusNotFound, "Could not find user")
+		case errors.Is(err, models.ErrFollowSelf):
+			app.errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, models.ErrAlreadyFollowing):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		case errors.Is(err, models.ErrBlockedRelation):
+			app.errorResponse(w, http.StatusForbidden, err.Error())
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *Application) UnfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	followeeID, ok := app.readUserIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	err := app.DB.DeleteFollow(user.ID, followeeID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFollowing) {
+			app.errorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *Application) ListFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.DB.GetFollowers, func(f models.Follow) int { return f.FollowerID })
+}
+
+func (app *Application) ListFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.DB.GetFollowing, func(f models.Follow) int { return f.FolloweeID })
+}
+
+// listFollows writes a page of follows returned by get. other picks the user on the
+// other end of each follow from the one in the URL path. Users that have blocked the
+// viewer or been blocked by them are left out, and if that includes the user in the
+// URL path a 404 is returned as GetUserHandler does.
+func (app *Application) listFollows(
+	w http.ResponseWriter,
+	r *http.Request,
+	get func(userID, viewerID, limit, offset int) ([]models.Follow, int, error),
+	other func(models.Follow) int,
+) {
+	userID, ok := app.readUserIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := app.readPagination(r)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	viewerID := 0
+	if viewer := app.contextGetUser(r); viewer != nil {
+		viewerID = viewer.ID
+	}
+	follows, total, err := get(userID, viewerID, limit, offset)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotExist) {
+			app.errorResponse(w, http.StatusNotFound, "Could not find user")
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	users := make([]followResponse, 0, len(follows))
+	for _, f := range follows {
+		users = append(users, followResponse{UserID: other(f), FollowedAt: f.CreatedAt})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{
+		"users":  users,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
@@ -2,8 +2,16 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
 )
 
 type envelope map[string]any
@@ -33,3 +41,34 @@ func (app *Application) errorResponse(w http.ResponseWriter, status int, message
 		w.WriteHeader(status)
 	}
 }
+
+// readPagination reads the "limit" and "offset" query parameters, falling back to the
+// defaults when they aren't given
+func (app *Application) readPagination(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, errors.New("limit must be between 1 and 100")
+		}
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// clientIP returns the IP address the request came from. Forwarding headers are ignored
+// since anyone can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
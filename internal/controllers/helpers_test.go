@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/moderation"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/password"
+)
+
+// newTestApp returns an application backed by an empty database in a temporary
+// directory, with a moderation pipeline that lets everything through
+func newTestApp(t *testing.T) *Application {
+	t.Helper()
+
+	db, err := models.NewDB(filepath.Join(t.TempDir(), "chirp_db.json"))
+	if err != nil {
+		t.Fatalf("could not establish database connection: %v", err)
+	}
+	db.SetPasswordHasher(password.Hasher{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4})
+
+	return &Application{DB: db, Moderation: moderation.NewPipeline()}
+}
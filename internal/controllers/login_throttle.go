@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+// reserveLoginAttempt counts a login attempt with email from ip as failed before the
+// credentials are checked. It writes a response and returns false if logins are blocked.
+func (app *Application) reserveLoginAttempt(w http.ResponseWriter, r *http.Request, email, ip string) bool {
+	blockedUntil, err := app.DB.ReserveLoginAttempt(email, ip, time.Now().UTC())
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return false
+	}
+	if wait := time.Until(blockedUntil); wait > 0 {
+		app.loginThrottledResponse(w, r, wait)
+		return false
+	}
+	return true
+}
+
+// releaseLoginAttempt takes back an attempt from reserveLoginAttempt that didn't fail
+func (app *Application) releaseLoginAttempt(email, ip string) {
+	err := app.DB.ReleaseLoginAttempt(email, ip)
+	if err != nil {
+		log.Printf("Error releasing login attempt: %s", err)
+	}
+}
+
+// UnlockUserLoginHandler lets an admin clear a user's failed logins so that they can log
+// in again straight away. Failures from IP addresses aren't affected.
+func (app *Application) UnlockUserLoginHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("userID"))
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	err = app.DB.UnlockLogin(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotExist) {
+			app.errorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
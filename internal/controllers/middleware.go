@@ -55,31 +55,33 @@ func (app *Application) isTokenJWT(token string) (bool, error) {
 	}
 }
 
-func (app *Application) getIDFromJWT(tokenPlaintext string) (int, error) {
-	token, err := jwt.Parse(tokenPlaintext, func(t *jwt.Token) (interface{}, error) {
+// getIDFromJWT returns the user ID and session ID from an access token. The session ID
+// is 0 for tokens issued before sessions were tracked.
+func (app *Application) getIDFromJWT(tokenPlaintext string) (int, int, error) {
+	var claims sessionClaims
+	token, err := jwt.ParseWithClaims(tokenPlaintext, &claims, func(t *jwt.Token) (interface{}, error) {
 		return []byte(app.Config.jwtSecret), nil
 	})
 	// Streamline the error handling logic later
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return 0, fmt.Errorf("Invalid token")
+	if !token.Valid {
+		return 0, 0, fmt.Errorf("Invalid token")
 	}
 
 	idStr, err := claims.GetSubject()
 	if err != nil {
-		return 0, fmt.Errorf("Could not retrieve user ID")
+		return 0, 0, fmt.Errorf("Could not retrieve user ID")
 	}
 
 	userID, err := strconv.Atoi(idStr)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	return userID, nil
+	return userID, claims.SessionID, nil
 }
 
 func (app *Application) MiddlewareAuthenticateJWT(next http.Handler) http.Handler {
@@ -117,12 +119,29 @@ func (app *Application) MiddlewareAuthenticateJWT(next http.Handler) http.Handle
 			return
 		}
 
-		userID, err := app.getIDFromJWT(token)
+		userID, sessionID, err := app.getIDFromJWT(token)
 		if err != nil {
 			app.invalidAuthenticationTokenResponse(w, r)
 			return
 		}
 
+		// Access tokens stop working as soon as their session is ended. Every access token
+		// is tied to a session, so one without a session ID is rejected.
+		if sessionID == 0 {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+		_, err = app.DB.GetUserSession(userID, sessionID)
+		if err != nil {
+			if errors.Is(err, models.ErrTokenNotExist) {
+				app.invalidAuthenticationTokenResponse(w, r)
+				return
+			}
+			app.serverErrorResponse(w, r)
+			return
+		}
+		r = app.contextSetSessionID(r, sessionID)
+
 		user, err := app.DB.GetUserByID(userID)
 		if err != nil {
 			switch {
@@ -187,9 +206,15 @@ func (app *Application) MiddlewareAuthenticateRefresh(next http.HandlerFunc) htt
 			next(w, r)
 			return
 		}
+		session, err := app.DB.GetRefreshToken(token)
+		if err != nil {
+			next(w, r)
+			return
+		}
 
-		// Add user to context
+		// Add user and session to context
 		r = app.contextSetUser(r, &user)
+		r = app.contextSetSessionID(r, session.ID)
 
 		next(w, r)
 	}
@@ -230,6 +255,39 @@ func (app *Application) MiddlewareRequireUser(next http.HandlerFunc) http.Handle
 			app.authenticationRequiredResponse(w, r)
 			return
 		}
+		if user.IsSuspended() {
+			app.accountSuspendedResponse(w, r)
+			return
+		}
+		if user.IsPendingDeletion() {
+			app.accountPendingDeletionResponse(w, r)
+			return
+		}
 		next(w, r)
 	}
 }
+
+func (app *Application) MiddlewareRequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return app.MiddlewareRequireUser(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if !user.IsAdmin {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// MiddlewareRequireVerified is MiddlewareRequireUser for actions that can be restricted
+// until the user has verified their email address. Whether action is restricted depends
+// on the application's UnverifiedRestrictions.
+func (app *Application) MiddlewareRequireVerified(action Restriction, next http.HandlerFunc) http.HandlerFunc {
+	return app.MiddlewareRequireUser(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if !user.EmailVerified && app.UnverifiedRestrictions[action] {
+			app.emailNotVerifiedResponse(w, r)
+			return
+		}
+		next(w, r)
+	})
+}
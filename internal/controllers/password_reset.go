@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/mailer"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+// sendPasswordReset emails a reset token to the user with the given email address if
+// there is one. Nothing is reported back to the client, so failures are only logged.
+func (app *Application) sendPasswordReset(email string) {
+	user, err := app.DB.GetUserByEmail(email)
+	if err != nil {
+		if !errors.Is(err, models.ErrUserNotExist) {
+			log.Printf("Error looking up user for password reset: %s", err)
+		}
+		return
+	}
+
+	token, err := app.DB.CreatePasswordResetToken(user.ID)
+	if err != nil {
+		if !errors.Is(err, models.ErrResetThrottled) {
+			log.Printf("Error creating password reset token for user %d: %s", user.ID, err)
+		}
+		return
+	}
+
+	if app.Mailer == nil {
+		return
+	}
+	err = app.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account. "+
+			"To choose a new password, send this token along with it to %s/api/password-reset/confirm:\n\n%s\n\n"+
+			"The token expires in 30 minutes and can only be used once. "+
+			"If you didn't ask for this you can ignore this email.\n", app.BaseURL, token),
+	})
+	if err != nil {
+		log.Printf("Error sending password reset email to user %d: %s", user.ID, err)
+	}
+}
+
+func (app *Application) RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON from the response body
+	var input struct {
+		Email string `json:"email"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	// The response is the same whether or not the account exists, and the work happens
+	// in the background so that response times don't give it away either
+	go app.sendPasswordReset(input.Email)
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{
+		"message": "If an account with that email address exists, a password reset email has been sent",
+	}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) ConfirmPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON from the response body
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	user, err := app.DB.GetPasswordResetUser(input.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrResetTokenInvalid) {
+			app.errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.PasswordPolicy.Check(input.Password, user.Email, user.Handle)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err = app.DB.ResetPassword(input.Token, input.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrResetTokenInvalid) {
+			app.errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Password has been reset"}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/validator"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+)
+
+type pollInput struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+type pollOptionResponse struct {
+	ID    int    `json:"id"`
+	Text  string `json:"text"`
+	Votes *int   `json:"votes,omitempty"`
+}
+
+// pollResponse is the poll as seen by a particular user. Vote counts are left out until
+// the user has voted or the poll has closed so that early results don't sway voters.
+type pollResponse struct {
+	Options        []pollOptionResponse `json:"options"`
+	ClosesAt       time.Time            `json:"closes_at"`
+	Closed         bool                 `json:"closed"`
+	ResultsVisible bool                 `json:"results_visible"`
+	TotalVotes     *int                 `json:"total_votes,omitempty"`
+	UserVote       int                  `json:"user_vote,omitempty"`
+}
+
+func newPollResponse(summary models.PollSummary) *pollResponse {
+	closed := summary.Poll.IsClosed(time.Now())
+	resp := &pollResponse{
+		ClosesAt:       summary.Poll.ClosesAt,
+		Closed:         closed,
+		ResultsVisible: closed || summary.UserVote != 0,
+		UserVote:       summary.UserVote,
+	}
+	if resp.ResultsVisible {
+		resp.TotalVotes = &summary.TotalVotes
+	}
+
+	for _, option := range summary.Poll.Options {
+		optionResp := pollOptionResponse{ID: option.ID, Text: option.Text}
+		if resp.ResultsVisible {
+			votes := summary.Tallies[option.ID]
+			optionResp.Votes = &votes
+		}
+		resp.Options = append(resp.Options, optionResp)
+	}
+
+	return resp
+}
+
+// moderatePoll runs the poll options through moderation in the same way as the chirp
+// body. Holds and flags apply to the whole chirp. It reports false if an option was
+// rejected.
+func (app *Application) moderatePoll(chirp *models.Chirp, poll *pollInput) (ok bool, reason string) {
+	for i, option := range poll.Options {
+		result := app.Moderation.Run(option)
+		if result.Rejected {
+			return false, result.RejectReason()
+		}
+		poll.Options[i] = result.Body
+		applyModeration(chirp, result)
+	}
+	return true, ""
+}
+
+// validatePoll normalizes the poll options and checks them against the poll limits. If
+// the poll is invalid an error response is written and false is returned.
+func (app *Application) validatePoll(w http.ResponseWriter, poll *pollInput, publishAt *time.Time) bool {
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		app.errorResponse(w, http.StatusBadRequest, "Polls must have between 2 and 4 options")
+		return false
+	}
+
+	seen := make(map[string]struct{}, len(poll.Options))
+	for i, option := range poll.Options {
+		option = strings.TrimSpace(validator.NormalizeText(option))
+		if option == "" {
+			app.errorResponse(w, http.StatusBadRequest, "Poll options can't be empty")
+			return false
+		}
+		if validator.CharCount(option) > maxPollOptionLength {
+			app.errorResponse(w, http.StatusBadRequest, "Poll option is too long")
+			return false
+		}
+
+		key := strings.ToLower(option)
+		if _, ok := seen[key]; ok {
+			app.errorResponse(w, http.StatusBadRequest, "Poll options must be unique")
+			return false
+		}
+		seen[key] = struct{}{}
+		poll.Options[i] = option
+	}
+
+	// The poll only starts running once the chirp is published
+	opensAt := time.Now()
+	if publishAt != nil {
+		opensAt = *publishAt
+	}
+	duration := poll.ClosesAt.Sub(opensAt)
+	if duration < minPollDuration || duration > maxPollDuration {
+		app.errorResponse(w, http.StatusBadRequest, "Polls must close between 5 minutes and 7 days after they open")
+		return false
+	}
+
+	return true
+}
+
+func (app *Application) CreatePollVoteHandler(w http.ResponseWriter, r *http.Request) {
+	// Get chirp ID from URL path
+	chirpIDStr := r.PathValue("chirpID")
+	chirpID, err := strconv.Atoi(chirpIDStr)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	// Decode the JSON from the response body
+	var input struct {
+		OptionID int `json:"option_id"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	// Users can only vote on chirps they can see
+	user := app.contextGetUser(r)
+	canView, err := app.chirpViewer(user)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	chirp, err := app.DB.GetChirpByID(chirpID)
+	if err != nil || !canView(chirp) {
+		app.errorResponse(w, http.StatusNotFound, "Chirp with that ID doesn't exist")
+		return
+	}
+
+	summary, err := app.DB.CastVote(chirpID, user.ID, input.OptionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrPollNotExist):
+			app.errorResponse(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, models.ErrInvalidPollOption):
+			app.errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, models.ErrPollClosed):
+			app.errorResponse(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, models.ErrAlreadyVoted):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, newPollResponse(summary), nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
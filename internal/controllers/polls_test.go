@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/filter"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/moderation"
+)
+
+func TestNewPollResponse(t *testing.T) {
+	poll := models.Poll{
+		Options: []models.PollOption{{ID: 1, Text: "yes"}, {ID: 2, Text: "no"}},
+	}
+	tallies := map[int]int{1: 3, 2: 1}
+
+	cases := []struct {
+		name        string
+		closesAt    time.Time
+		userVote    int
+		wantVisible bool
+	}{
+		{"Test tallies hidden before voting", time.Now().Add(time.Hour), 0, false},
+		{"Test tallies shown after voting", time.Now().Add(time.Hour), 2, true},
+		{"Test tallies shown once closed", time.Now().Add(-time.Hour), 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			poll.ClosesAt = c.closesAt
+			resp := newPollResponse(models.PollSummary{Poll: poll, Tallies: tallies, TotalVotes: 4, UserVote: c.userVote})
+			if resp.ResultsVisible != c.wantVisible {
+				t.Errorf("Expected results visible to be %v\ngot %v", c.wantVisible, resp.ResultsVisible)
+			}
+			for _, option := range resp.Options {
+				if (option.Votes != nil) != c.wantVisible {
+					t.Errorf("Expected votes for option %d to be shown: %v\ngot %v", option.ID, c.wantVisible, option.Votes)
+				}
+			}
+			if (resp.TotalVotes != nil) != c.wantVisible {
+				t.Errorf("Expected total votes to be shown: %v\ngot %v", c.wantVisible, resp.TotalVotes)
+			}
+		})
+	}
+}
+
+func TestModeratePoll(t *testing.T) {
+	flagList := filter.New(filter.List{Words: map[string]string{"spoiler": filter.DefaultReplacement}})
+	holdList := filter.New(filter.DefaultList())
+	app := Application{
+		Moderation: moderation.NewPipeline(
+			moderation.KeywordStage{Name: "flag", Action: models.ModerationFlag, Filter: flagList},
+			moderation.KeywordStage{Name: "hold", Action: models.ModerationHold, Filter: holdList},
+		),
+	}
+
+	cases := []struct {
+		name        string
+		status      models.ChirpStatus
+		options     []string
+		wantStatus  models.ChirpStatus
+		wantFlagged bool
+	}{
+		{"Test clean options", models.ChirpPublished, []string{"yes", "no"}, models.ChirpPublished, false},
+		{"Test held option holds chirp", models.ChirpPublished, []string{"yes", "sharbert"}, models.ChirpHeld, false},
+		{"Test flagged option flags chirp", models.ChirpPublished, []string{"spoiler", "no"}, models.ChirpPublished, true},
+		{"Test held chirp stays held", models.ChirpHeld, []string{"yes", "no"}, models.ChirpHeld, false},
+		{"Test later clean option doesn't release hold", models.ChirpPublished, []string{"sharbert", "no"}, models.ChirpHeld, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			chirp := models.Chirp{Body: "poll", Status: c.status}
+			ok, _ := app.moderatePoll(&chirp, &pollInput{Options: c.options})
+			if !ok {
+				t.Fatalf("Poll was rejected")
+			}
+			if chirp.Status != c.wantStatus {
+				t.Errorf("Expected '%v'\ngot '%v'", c.wantStatus, chirp.Status)
+			}
+			if chirp.Flagged != c.wantFlagged {
+				t.Errorf("Expected flagged to be %v\ngot %v", c.wantFlagged, chirp.Flagged)
+			}
+			if len(chirp.Moderation) == 0 {
+				t.Errorf("Expected the decisions to be recorded on the chirp")
+			}
+		})
+	}
+}
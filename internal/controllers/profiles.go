@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/validator"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+)
+
+// profileResponse is the public view of a user that anyone can see. Fields are copied
+// from models.User one by one so that private data such as the email address and
+// password hash can never end up in it.
+type profileResponse struct {
+	ID          int    `json:"id"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Avatar      string `json:"avatar"`
+	models.FollowCounts
+}
+
+func newProfileResponse(user models.User, counts models.FollowCounts) profileResponse {
+	return profileResponse{
+		ID:           user.ID,
+		Handle:       user.Handle,
+		DisplayName:  user.DisplayName,
+		Bio:          user.Bio,
+		Avatar:       user.Avatar,
+		FollowCounts: counts,
+	}
+}
+
+// profileRejectedError is returned when moderation rejects part of a profile
+type profileRejectedError struct {
+	reason string
+}
+
+func (e profileRejectedError) Error() string {
+	if e.reason == "" {
+		return "Rejected by moderation"
+	}
+	return "Rejected by moderation: " + e.reason
+}
+
+func (app *Application) profileRejectedResponse(w http.ResponseWriter, reason string) {
+	app.errorResponse(w, http.StatusUnprocessableEntity, envelope{
+		"message": "Profile was rejected by moderation",
+		"reason":  reason,
+	})
+}
+
+// profileErrorResponse writes the response for an error returned by checkHandle or
+// checkProfileText
+func (app *Application) profileErrorResponse(w http.ResponseWriter, err error) {
+	var rejected profileRejectedError
+	if errors.As(err, &rejected) {
+		app.profileRejectedResponse(w, rejected.reason)
+		return
+	}
+	app.errorResponse(w, http.StatusBadRequest, err.Error())
+}
+
+// checkHandle normalizes a handle and checks it against the handle rules and moderation
+func (app *Application) checkHandle(handle string) (string, error) {
+	handle, err := validator.Handle(handle)
+	if err != nil {
+		return "", err
+	}
+
+	// Handles can't be masked, so anything moderation would change is rejected
+	result := app.Moderation.Run(handle)
+	if result.Rejected {
+		return "", profileRejectedError{result.RejectReason()}
+	}
+	if result.Body != handle {
+		return "", profileRejectedError{"Handle contains words that aren't allowed"}
+	}
+
+	return handle, nil
+}
+
+// checkProfileText normalizes a profile field, checks its length and runs it through
+// moderation. field is the name of the field used in error messages.
+func (app *Application) checkProfileText(field, text string, maxLength int) (string, error) {
+	text = strings.TrimSpace(validator.NormalizeText(text))
+	if validator.CharCount(text) > maxLength {
+		return "", fmt.Errorf("%s is too long", field)
+	}
+
+	result := app.Moderation.Run(text)
+	if result.Rejected {
+		return "", profileRejectedError{result.RejectReason()}
+	}
+
+	return result.Body, nil
+}
+
+// cleanDisplayName collapses a display name onto a single line before it is checked
+func cleanDisplayName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// validateHandle is checkHandle for handlers that only deal with a handle. If the
+// handle is invalid an error response is written and false is returned.
+func (app *Application) validateHandle(w http.ResponseWriter, handle string) (string, bool) {
+	handle, err := app.checkHandle(handle)
+	if err != nil {
+		app.profileErrorResponse(w, err)
+		return "", false
+	}
+	return handle, true
+}
+
+// GetUserHandler returns the public profile of the user in the URL path, which can be
+// either their ID or their handle
+func (app *Application) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.lookupUser(r.PathValue("user"))
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotExist) {
+			app.errorResponse(w, http.StatusNotFound, "Could not find user")
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	// Accounts waiting to be deleted are hidden in case the deletion goes ahead
+	if user.IsPendingDeletion() {
+		app.errorResponse(w, http.StatusNotFound, "Could not find user")
+		return
+	}
+
+	// Users that have blocked each other can't see each other's profiles
+	if viewer := app.contextGetUser(r); viewer != nil {
+		blocked, err := app.DB.IsBlocked(viewer.ID, user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r)
+			return
+		}
+		if blocked {
+			app.errorResponse(w, http.StatusNotFound, "Could not find user")
+			return
+		}
+	}
+
+	counts, err := app.DB.GetFollowCounts(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newProfileResponse(user, counts), nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+// lookupUser finds a user by ID or handle. Handles always contain a letter so there is
+// no overlap between the two.
+func (app *Application) lookupUser(ref string) (models.User, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return app.DB.GetUserByID(id)
+	}
+
+	// Anything that isn't a valid handle can't belong to anyone
+	handle, err := validator.Handle(ref)
+	if err != nil {
+		return models.User{}, models.ErrUserNotExist
+	}
+	return app.DB.GetUserByHandle(handle)
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/validator"
+)
+
+const maxReportDetailsLength = 500
+
+// reportResponse is a report as seen by admins. It includes how the moderation pipeline
+// dealt with the reported chirp, which is hidden everywhere else.
+type reportResponse struct {
+	models.Report
+	Flagged    bool                        `json:"flagged,omitempty"`
+	Moderation []models.ModerationDecision `json:"moderation,omitempty"`
+}
+
+func (app *Application) CreateReportHandler(w http.ResponseWriter, r *http.Request) {
+	// Get chirp ID from URL path
+	chirpIDStr := r.PathValue("chirpID")
+	chirpID, err := strconv.Atoi(chirpIDStr)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	// Decode the JSON from the response body
+	var input struct {
+		Reason  models.ReportReason `json:"reason"`
+		Details string              `json:"details"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	details := validator.NormalizeText(input.Details)
+	if validator.CharCount(details) > maxReportDetailsLength {
+		app.errorResponse(w, http.StatusBadRequest, "Report details are too long")
+		return
+	}
+
+	// Users can only report chirps they can see, and not their own
+	user := app.contextGetUser(r)
+	canView, err := app.chirpViewer(user)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	chirp, err := app.DB.GetChirpByID(chirpID)
+	if err != nil || !canView(chirp) {
+		app.errorResponse(w, http.StatusNotFound, "Chirp with that ID doesn't exist")
+		return
+	}
+	if chirp.AuthorID == user.ID {
+		app.errorResponse(w, http.StatusBadRequest, "You can't report your own chirp")
+		return
+	}
+
+	report, err := app.DB.CreateReport(chirpID, user.ID, input.Reason, details)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidReportReason):
+			app.errorResponse(w, http.StatusBadRequest, envelope{
+				"message": err.Error(),
+				"reasons": models.ReportReasons,
+			})
+		case errors.Is(err, models.ErrReportDuplicate):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		case errors.Is(err, models.ErrChirpNotExist):
+			app.errorResponse(w, http.StatusNotFound, "Chirp with that ID doesn't exist")
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, report, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) ListReportsHandler(w http.ResponseWriter, r *http.Request) {
+	// Only show the open reports unless a different status is requested
+	status := models.ReportStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = models.ReportOpen
+	case "all":
+		status = ""
+	case models.ReportOpen, models.ReportResolved:
+	default:
+		app.errorResponse(w, http.StatusBadRequest, "Invalid report status")
+		return
+	}
+
+	reports, err := app.DB.GetReports(status)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	chirps, err := app.DB.GetChirps()
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	chirpsByID := make(map[int]models.Chirp, len(chirps))
+	for _, chirp := range chirps {
+		chirpsByID[chirp.ID] = chirp
+	}
+
+	output := make([]reportResponse, 0, len(reports))
+	for _, report := range reports {
+		chirp := chirpsByID[report.ChirpID]
+		output = append(output, reportResponse{
+			Report:     report,
+			Flagged:    chirp.Flagged,
+			Moderation: chirp.Moderation,
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, output, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) ResolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	// Get report ID from URL path
+	reportIDStr := r.PathValue("reportID")
+	reportID, err := strconv.Atoi(reportIDStr)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	// Decode the JSON from the response body
+	var input struct {
+		Action models.ReportAction `json:"action"`
+		Note   string              `json:"note"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	admin := app.contextGetUser(r)
+	report, err := app.DB.ResolveReport(reportID, admin.ID, input.Action, validator.NormalizeText(input.Note))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidReportAction):
+			app.errorResponse(w, http.StatusBadRequest, envelope{
+				"message": err.Error(),
+				"actions": models.ReportActions,
+			})
+		case errors.Is(err, models.ErrReportNotExist):
+			app.errorResponse(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, models.ErrReportResolved):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		case errors.Is(err, models.ErrUserNotExist):
+			app.errorResponse(w, http.StatusNotFound, "Author of the chirp no longer exists")
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, report, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) ModerationLogHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := app.DB.GetModerationLog()
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, entries, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+func TestModerationDetailsOnlyForAdmins(t *testing.T) {
+	app := newTestApp(t)
+	db := app.DB
+
+	chirp, err := db.CreateChirp(models.Chirp{
+		Body:       "chirp",
+		AuthorID:   1,
+		Flagged:    true,
+		Moderation: []models.ModerationDecision{{Stage: "keywords", Action: models.ModerationFlag}},
+	})
+	if err != nil {
+		t.Fatalf("could not create chirp: %v", err)
+	}
+	_, err = db.CreateReport(chirp.ID, 2, models.ReportSpam, "")
+	if err != nil {
+		t.Fatalf("could not create report: %v", err)
+	}
+
+	cases := []struct {
+		name           string
+		handler        http.HandlerFunc
+		user           *models.User
+		wantModeration bool
+	}{
+		{"Test report listing", app.ListReportsHandler, &models.User{ID: 3, IsAdmin: true}, true},
+		{"Test single chirp for author", app.GetSingleChirpHandler, &models.User{ID: 1}, false},
+		{"Test chirp listing", app.GetChirpsHandler, nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.SetPathValue("chirpID", strconv.Itoa(chirp.ID))
+			if c.user != nil {
+				r = app.contextSetUser(r, c.user)
+			}
+			c.handler(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d\ngot %d: %s", http.StatusOK, w.Code, w.Body)
+			}
+			body := w.Body.String()
+			if got := strings.Contains(body, `"moderation"`) && strings.Contains(body, `"flagged"`); got != c.wantModeration {
+				t.Errorf("Expected moderation details to be shown to be %v\ngot %s", c.wantModeration, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// ListScheduledChirpsHandler returns the current user's chirps that are still waiting
+// to be published, soonest first
+func (app *Application) ListScheduledChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	chirps, err := app.DB.GetChirps()
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't load chirps from database")
+		return
+	}
+
+	user := app.contextGetUser(r)
+	scheduled := []models.Chirp{}
+	for _, chirp := range chirps {
+		if chirp.AuthorID == user.ID && chirp.Status == models.ChirpScheduled {
+			scheduled = append(scheduled, chirp)
+		}
+	}
+	slices.SortFunc(scheduled, func(a, b models.Chirp) int {
+		return cmp.Compare(a.PublishAt.Unix(), b.PublishAt.Unix())
+	})
+
+	output, err := app.chirpResponses(user, scheduled)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, output, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+// CancelScheduledChirpHandler deletes a scheduled chirp before it is published
+func (app *Application) CancelScheduledChirpHandler(w http.ResponseWriter, r *http.Request) {
+	// Get chirp ID from URL path
+	chirpIDStr := r.PathValue("chirpID")
+	chirpID, err := strconv.Atoi(chirpIDStr)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	// Only the author can see that a scheduled chirp exists
+	user := app.contextGetUser(r)
+	err = app.DB.CancelScheduledChirp(chirpID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrChirpNotExist):
+			app.errorResponse(w, http.StatusNotFound, "Scheduled chirp with that ID doesn't exist")
+		case errors.Is(err, models.ErrChirpPublished),
+			errors.Is(err, models.ErrChirpHeld),
+			errors.Is(err, models.ErrChirpNotScheduled):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
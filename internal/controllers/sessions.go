@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+const maxDeviceNameLength = 100
+
+// sessionResponse describes a session without giving away its refresh token. Current
+// is set for the session the request was made with.
+type sessionResponse struct {
+	ID         int       `json:"id"`
+	DeviceName string    `json:"device_name,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// sessionDeviceName returns the name a new session should be listed under. Clients can
+// name themselves when logging in, otherwise the User-Agent header is used.
+func sessionDeviceName(r *http.Request, name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = r.UserAgent()
+	}
+	name = strings.Join(strings.Fields(name), " ")
+
+	runes := []rune(name)
+	if len(runes) > maxDeviceNameLength {
+		name = string(runes[:maxDeviceNameLength])
+	}
+	return name
+}
+
+// ListSessionsHandler lists the devices the signed-in user is logged in on
+func (app *Application) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	sessions, err := app.DB.GetUserSessions(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	currentID := app.contextGetSessionID(r)
+	output := make([]sessionResponse, len(sessions))
+	for i, session := range sessions {
+		output[i] = sessionResponse{
+			ID:         session.ID,
+			DeviceName: session.DeviceName,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.Expiry,
+			Current:    session.ID == currentID,
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, output, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+// DeleteSessionHandler logs the signed-in user out of one of their sessions. Access
+// tokens issued for the session stop working straight away.
+func (app *Application) DeleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.Atoi(r.PathValue("sessionID"))
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	user := app.contextGetUser(r)
+	err = app.DB.DeleteUserSession(user.ID, sessionID)
+	if err != nil {
+		if errors.Is(err, models.ErrTokenNotExist) {
+			app.errorResponse(w, http.StatusNotFound, "Session does not exist")
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteAllSessionsHandler logs the signed-in user out everywhere, including the
+// session the request was made with
+func (app *Application) DeleteAllSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	err := app.DB.DeleteUserSessions(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/thumbnail"
+)
+
+// MiddlewareThumbnail serves a resized copy of the requested image when the request has
+// a "w" query parameter. All other requests are passed through to next.
+func (app *Application) MiddlewareThumbnail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		widthStr := r.URL.Query().Get("w")
+		if widthStr == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		width, err := strconv.Atoi(widthStr)
+		if err != nil {
+			app.errorResponse(w, http.StatusBadRequest, "Invalid thumbnail width")
+			return
+		}
+
+		thumbPath, err := app.Thumbnails.Get(r.Context(), r.URL.Path, width)
+		if err != nil {
+			switch {
+			case errors.Is(err, thumbnail.ErrUnsupportedSize):
+				app.errorResponse(w, http.StatusBadRequest, map[string]any{
+					"message":         "Unsupported thumbnail width",
+					"supported_sizes": thumbnail.Sizes,
+				})
+			case errors.Is(err, thumbnail.ErrUnsupportedFormat):
+				app.errorResponse(w, http.StatusBadRequest, "File is not a supported image")
+			case errors.Is(err, os.ErrNotExist):
+				http.NotFound(w, r)
+			case errors.Is(err, thumbnail.ErrBusy):
+				w.Header().Set("Retry-After", "1")
+				app.errorResponse(w, http.StatusServiceUnavailable, "Thumbnail service is busy, try again later")
+			case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+				// Client went away before the thumbnail was ready
+			default:
+				app.serverErrorResponse(w, r)
+			}
+			return
+		}
+
+		http.ServeFile(w, r, thumbPath)
+	})
+}
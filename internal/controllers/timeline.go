@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+// TimelineHandler returns the current user's home timeline: their own chirps and the
+// chirps of the users they follow, newest first. Pages are fetched with the opaque
+// "cursor" query parameter from the previous response.
+func (app *Application) TimelineHandler(w http.ResponseWriter, r *http.Request) {
+	limit, _, err := app.readPagination(r)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var cursor *models.TimelineCursor
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		c, err := models.ParseTimelineCursor(cursorStr)
+		if err != nil {
+			app.errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		cursor = &c
+	}
+
+	user := app.contextGetUser(r)
+	canView, err := app.chirpViewer(user)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+	chirps, next, err := app.DB.GetTimeline(user.ID, cursor, limit, canView)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	output, err := app.chirpResponses(user, chirps)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	var nextCursor *string
+	if next != nil {
+		s := next.String()
+		nextCursor = &s
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{
+		"chirps":      output,
+		"next_cursor": nextCursor,
+	}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
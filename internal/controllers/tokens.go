@@ -1,16 +1,26 @@
 package controllers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
 )
 
 func (app *Application) RefreshAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
 	// Get user from request context
 	user := app.contextGetUser(r)
 
+	sessionID := app.contextGetSessionID(r)
+	err := app.DB.TouchRefreshToken(sessionID, clientIP(r), time.Now().UTC())
+	if err != nil {
+		log.Printf("Error updating session: %s", err)
+	}
+
 	// Create new JWT for the current user
-	token, err := app.generateJWT(user.ID, nil)
+	token, err := app.generateJWT(user.ID, sessionID, nil)
 	if err != nil {
 		app.errorResponse(w, http.StatusInternalServerError, "Could not create JWT")
 		return
@@ -32,16 +42,13 @@ func (app *Application) RevokeRefreshTokenHandler(w http.ResponseWriter, r *http
 	// Get user from request context
 	user := app.contextGetUser(r)
 
-	// Get token by user id
-	token, err := app.DB.GetTokenByUserID(user.ID)
-	if err != nil {
-		app.errorResponse(w, http.StatusInternalServerError, "The user does not have a refresh token")
-		return
-	}
-
-	// Delete the token using the token's id
-	err = app.DB.DeleteRefreshToken(token.ID)
+	// Only the session the refresh token belongs to is ended
+	err := app.DB.DeleteUserSession(user.ID, app.contextGetSessionID(r))
 	if err != nil {
+		if errors.Is(err, models.ErrTokenNotExist) {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
 		app.serverErrorResponse(w, r)
 		return
 	}
@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/totp"
+)
+
+const (
+	// totpIssuer is the name authenticator apps show next to the code
+	totpIssuer = "Chirpy"
+
+	twoFactorLoginExpiry = 5 * time.Minute
+)
+
+var errInvalidTwoFactorToken = errors.New("Login has expired, log in with your password again")
+
+// signTwoFactorToken creates a token proving that the user logged in with their
+// password and still has to send a second factor. The token is "payload.signature"
+// where the payload is "userID:expiry", both base64url encoded.
+func (app *Application) signTwoFactorToken(userID int, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%d:%d", userID, expiresAt.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(app.twoFactorMAC(payload))
+}
+
+// parseTwoFactorToken checks the signature and expiry of a token made by
+// signTwoFactorToken and returns the user ID it was made for
+func (app *Application) parseTwoFactorToken(token string, now time.Time) (int, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, errInvalidTwoFactorToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return 0, errInvalidTwoFactorToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return 0, errInvalidTwoFactorToken
+	}
+	if !hmac.Equal(mac, app.twoFactorMAC(string(payload))) {
+		return 0, errInvalidTwoFactorToken
+	}
+
+	userIDStr, expiryStr, ok := strings.Cut(string(payload), ":")
+	if !ok {
+		return 0, errInvalidTwoFactorToken
+	}
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return 0, errInvalidTwoFactorToken
+	}
+	expiry, err := strconv.ParseInt(expiryStr, 10, 64)
+	if err != nil || !now.Before(time.Unix(expiry, 0)) {
+		return 0, errInvalidTwoFactorToken
+	}
+
+	return userID, nil
+}
+
+// twoFactorMAC signs payload with a key derived from the JWT secret, using a different
+// prefix from verificationMAC so the two kinds of token can't be swapped
+func (app *Application) twoFactorMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(app.Config.jwtSecret))
+	mac.Write([]byte("two-factor-login:" + payload))
+	return mac.Sum(nil)
+}
+
+// LoginTwoFactorHandler is the second step of logging in for users with two-factor
+// authentication. It takes the token from LoginHandler along with a TOTP code or a
+// recovery code, and wrong codes count as failed logins.
+func (app *Application) LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON from the response body
+	var input struct {
+		TwoFactorToken   string `json:"two_factor_token"`
+		Code             string `json:"code"`
+		DeviceName       string `json:"device_name"`
+		ExpiresInSeconds *int   `json:"expires_in_seconds"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	now := time.Now()
+	userID, err := app.parseTwoFactorToken(input.TwoFactorToken, now)
+	if err != nil {
+		app.errorResponse(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	user, err := app.DB.GetUserByID(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotExist) {
+			app.errorResponse(w, http.StatusUnauthorized, errInvalidTwoFactorToken.Error())
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	ip := clientIP(r)
+	if !app.reserveLoginAttempt(w, r, user.Email, ip) {
+		return
+	}
+
+	remaining, err := app.DB.VerifySecondFactor(user.ID, input.Code, now)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidTOTPCode):
+			app.errorResponse(w, http.StatusUnauthorized, models.ErrInvalidTOTPCode.Error())
+		case errors.Is(err, models.ErrTOTPNotEnabled):
+			// Two-factor authentication was turned off after the password was checked
+			app.releaseLoginAttempt(user.Email, ip)
+			app.errorResponse(w, http.StatusUnauthorized, errInvalidTwoFactorToken.Error())
+		default:
+			app.releaseLoginAttempt(user.Email, ip)
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+	app.releaseLoginAttempt(user.Email, ip)
+	if remaining == 0 {
+		log.Printf("User %d has used all of their recovery codes", user.ID)
+	}
+
+	if user.IsSuspended() {
+		app.accountSuspendedResponse(w, r)
+		return
+	}
+
+	app.completeLogin(w, r, user, input.DeviceName, input.ExpiresInSeconds)
+}
+
+// EnrolTOTPHandler starts turning on two-factor authentication for the signed-in user.
+// It returns a new secret and the provisioning URI to show as a QR code, and the user
+// then has to confirm that their app works with ConfirmTOTPHandler.
+func (app *Application) EnrolTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON from the response body
+	var input struct {
+		Password string `json:"password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	user := app.contextGetUser(r)
+	err = app.DB.CheckPassword(*user, input.Password)
+	if err != nil {
+		app.errorResponse(w, http.StatusUnauthorized, "Password is incorrect")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.DB.BeginTOTPEnrolment(user.ID, secret)
+	if err != nil {
+		if errors.Is(err, models.ErrTOTPAlreadyEnabled) {
+			app.errorResponse(w, http.StatusConflict, err.Error())
+			return
+		}
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(secret, totpIssuer, user.Email),
+	}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+// ConfirmTOTPHandler turns on two-factor authentication once the user sends a code from
+// their app. The recovery codes in the response are never shown again.
+func (app *Application) ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON from the response body
+	var input struct {
+		Code string `json:"code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	user := app.contextGetUser(r)
+	recoveryCodes, err := app.DB.ConfirmTOTP(user.ID, input.Code, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidTOTPCode):
+			app.errorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, models.ErrTOTPAlreadyEnabled):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		case errors.Is(err, models.ErrTOTPNotEnrolling):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"recovery_codes": recoveryCodes}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+// DisableTOTPHandler turns off two-factor authentication. It needs the password and a
+// current code so that a stolen session can't be used to remove the second factor, and
+// wrong ones count as failed logins so that they can't be guessed here instead.
+func (app *Application) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON from the response body
+	var input struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Couldn't decode parameters")
+		return
+	}
+
+	user := app.contextGetUser(r)
+	ip := clientIP(r)
+	if !app.reserveLoginAttempt(w, r, user.Email, ip) {
+		return
+	}
+
+	err = app.DB.CheckPassword(*user, input.Password)
+	if err != nil {
+		app.errorResponse(w, http.StatusUnauthorized, "Password is incorrect")
+		return
+	}
+
+	_, err = app.DB.VerifySecondFactor(user.ID, input.Code, time.Now())
+	if errors.Is(err, models.ErrInvalidTOTPCode) {
+		app.errorResponse(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	app.releaseLoginAttempt(user.Email, ip)
+	if err == nil {
+		err = app.DB.DisableTOTP(user.ID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrTOTPNotEnabled):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
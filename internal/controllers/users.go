@@ -7,18 +7,60 @@ import (
 	"log"
 	"net/http"
 	"net/mail"
+	"strings"
 	"time"
 
 	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/validator"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 const JWTDefaultExpiry = 1 * time.Hour
 
 // Implement a better validation system later. For now, just make sure that everything works.
 
-func (app *Application) generateJWT(userId int, expiryInSeconds *int) (string, error) {
+// userResponse is the private view of a user that is only sent to the user themselves.
+// Like profileResponse it never embeds models.User so that the password hash can't leak.
+type userResponse struct {
+	ID          int    `json:"id"`
+	Email       string `json:"email"`
+	Handle      string `json:"handle,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Bio         string `json:"bio,omitempty"`
+	Avatar      string `json:"avatar,omitempty"`
+	IsChirpyRed bool   `json:"is_chirpy_red"`
+	IsAdmin     bool   `json:"is_admin,omitempty"`
+
+	EmailVerified    bool `json:"email_verified"`
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+	models.FollowCounts
+}
+
+func newUserResponse(user models.User, counts models.FollowCounts) userResponse {
+	return userResponse{
+		ID:          user.ID,
+		Email:       user.Email,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Avatar:      user.Avatar,
+		IsChirpyRed: user.IsChirpyRed,
+		IsAdmin:     user.IsAdmin,
+
+		EmailVerified:    user.EmailVerified,
+		TwoFactorEnabled: user.TOTPEnabled,
+		FollowCounts:     counts,
+	}
+}
+
+// sessionClaims are the claims in access tokens. SessionID ties the token to the refresh
+// token it was issued alongside so that it stops working when that session is ended.
+type sessionClaims struct {
+	SessionID int `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (app *Application) generateJWT(userId, sessionID int, expiryInSeconds *int) (string, error) {
 	// Create claims
 	defaultExpiry := time.Now().Add(JWTDefaultExpiry)
 	var expiresAt time.Time
@@ -30,11 +72,14 @@ func (app *Application) generateJWT(userId int, expiryInSeconds *int) (string, e
 			expiresAt = defaultExpiry
 		}
 	}
-	claims := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(expiresAt),
-		Subject:   fmt.Sprintf("%d", userId),
+	claims := sessionClaims{
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			Subject:   fmt.Sprintf("%d", userId),
+		},
 	}
 
 	// Create JWT
@@ -51,6 +96,7 @@ func (app *Application) CreateUserHandler(w http.ResponseWriter, r *http.Request
 	var input struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
@@ -74,43 +120,50 @@ func (app *Application) CreateUserHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	if exists {
-		app.errorResponse(w, http.StatusBadRequest, "Account with that email address already exists")
+		app.errorResponse(w, http.StatusConflict, models.ErrEmailTaken.Error())
 		return
 	}
 
+	// The handle is optional when signing up and can be set later
+	handle := ""
+	if input.Handle != "" {
+		var ok bool
+		handle, ok = app.validateHandle(w, input.Handle)
+		if !ok {
+			return
+		}
+	}
+
 	// Check that the password is valid
-	// Will take out the more stringent password requirements for now
-	if input.Password == "" {
-		app.errorResponse(w, http.StatusBadRequest, "Password must be provided")
+	err = app.PasswordPolicy.Check(input.Password, input.Email, handle)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	// if len(input.Password) < 8 {
-	// 	errorResponse(w, http.StatusBadRequest, "Password must be at least 8 characters long.")
-	// 	return
-	// }
-	// if len(input.Password) > 72 {
-	// 	errorResponse(w, http.StatusBadRequest, "Password must not be more than 72 characters long.")
-	// 	return
-	// }
 
 	// Create user
-	user, err := app.DB.CreateUser(input.Email, input.Password)
+	user, err := app.DB.CreateUser(input.Email, input.Password, handle)
 	if err != nil {
-		app.errorResponse(w, http.StatusInternalServerError, "Couldn't create chirp")
+		switch {
+		case errors.Is(err, models.ErrEmailTaken):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		case errors.Is(err, models.ErrHandleTaken):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Couldn't create chirp")
+		}
 		return
 	}
 
-	// Response is valid
-	output := struct {
-		ID          int    `json:"id"`
-		Email       string `json:"email"`
-		IsChirpyRed bool   `json:"is_chirpy_red"`
-	}{
-		user.ID,
-		user.Email,
-		user.IsChirpyRed,
+	// The account can be used straight away but some actions may be restricted until the
+	// email address is verified
+	err = app.sendVerificationEmail(user)
+	if err != nil {
+		log.Printf("Error sending verification email: %s", err)
 	}
-	err = app.writeJSON(w, http.StatusCreated, output, nil)
+
+	// Response is valid
+	err = app.writeJSON(w, http.StatusCreated, newUserResponse(user, models.FollowCounts{}), nil)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
 	}
@@ -121,6 +174,7 @@ func (app *Application) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Email            string `json:"email"`
 		Password         string `json:"password"`
+		DeviceName       string `json:"device_name"`
 		ExpiresInSeconds *int   `json:"expires_in_seconds"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&input)
@@ -129,48 +183,102 @@ func (app *Application) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user exists in database and retrieve their info if they do
-	user, err := app.DB.GetUserByEmail(input.Email)
+	// Failed logins are throttled per email address and per IP address. Every failure
+	// gets the same response so that it isn't possible to tell which email addresses
+	// have accounts. The attempt is counted as a failure before the password is checked
+	// and taken back if it turns out to be right.
+	ip := clientIP(r)
+	if !app.reserveLoginAttempt(w, r, input.Email, ip) {
+		return
+	}
+
+	user, err := app.DB.Authenticate(input.Email, input.Password)
 	if err != nil {
-		if errors.Is(err, models.ErrUserNotExist) {
-			app.errorResponse(w, http.StatusBadRequest, "No user with this email exists")
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			app.releaseLoginAttempt(input.Email, ip)
+			app.serverErrorResponse(w, r)
 			return
 		}
-		app.errorResponse(w, http.StatusInternalServerError, "Problem checking database")
+		app.errorResponse(w, http.StatusUnauthorized, models.ErrInvalidCredentials.Error())
+		return
+	}
+	app.releaseLoginAttempt(input.Email, ip)
+
+	// Upgrade hashes made with outdated settings now that we have the password. Logging
+	// in still works if this fails since the old hash is kept.
+	rehashed, err := app.DB.RehashPassword(user, input.Password)
+	if err != nil {
+		log.Printf("Error rehashing password: %s", err)
+	} else {
+		user = rehashed
+	}
+
+	if user.IsSuspended() {
+		app.accountSuspendedResponse(w, r)
+		return
+	}
+
+	// Users with two-factor authentication have to send a code to LoginTwoFactorHandler
+	// before they get any tokens
+	if user.TOTPEnabled {
+		expiresAt := time.Now().Add(twoFactorLoginExpiry)
+		err = app.writeJSON(w, http.StatusOK, envelope{
+			"two_factor_required": true,
+			"two_factor_token":    app.signTwoFactorToken(user.ID, expiresAt),
+			"expires_at":          expiresAt.UTC(),
+		}, nil)
+		if err != nil {
+			log.Printf("Error marshalling JSON: %s", err)
+		}
 		return
 	}
 
-	// Compare the password in the request to the existing user's password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
+	app.completeLogin(w, r, user, input.DeviceName, input.ExpiresInSeconds)
+}
+
+// completeLogin issues tokens to a user who has proven who they are
+func (app *Application) completeLogin(w http.ResponseWriter, r *http.Request, user models.User, deviceName string, expiresInSeconds *int) {
+	err := app.DB.ClearLoginFailures(user.Email)
+	if err != nil {
+		log.Printf("Error clearing failed logins: %s", err)
+	}
+
+	// Logging in during the grace period cancels a pending account deletion
+	if user.IsPendingDeletion() {
+		user, err = app.DB.CancelUserDeletion(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r)
+			return
+		}
+	}
+
+	// Create refresh token for a new session
+	refreshToken, err := app.DB.CreateRefreshToken(user.ID, sessionDeviceName(r, deviceName), clientIP(r))
 	if err != nil {
-		app.errorResponse(w, http.StatusUnauthorized, "Password is incorrect")
+		app.errorResponse(w, http.StatusInternalServerError, "Could not create refresh token")
 		return
 	}
 
 	// Create JWT
-	token, err := app.generateJWT(user.ID, input.ExpiresInSeconds)
+	token, err := app.generateJWT(user.ID, refreshToken.ID, expiresInSeconds)
 	if err != nil {
 		app.errorResponse(w, http.StatusInternalServerError, "Could not create JWT")
 		return
 	}
 
-	// Create refresh token
-	refreshToken, err := app.DB.CreateRefreshToken(user.ID)
+	counts, err := app.DB.GetFollowCounts(user.ID)
 	if err != nil {
-		app.errorResponse(w, http.StatusInternalServerError, "Could not create refresh token")
+		app.serverErrorResponse(w, r)
+		return
 	}
 
 	// Return user info sans password on successful login
 	output := struct {
-		ID           int    `json:"id"`
-		Email        string `json:"email"`
-		IsChirpyRed  bool   `json:"is_chirpy_red"`
+		userResponse
 		Token        string `json:"token"`
 		RefreshToken string `json:"refresh_token"`
 	}{
-		user.ID,
-		user.Email,
-		user.IsChirpyRed,
+		newUserResponse(user, counts),
 		token,
 		refreshToken.Plaintext,
 	}
@@ -180,11 +288,19 @@ func (app *Application) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (app *Application) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
-	// Get updated email and password from request body
+// UpdateCurrentUserHandler applies a partial update to the signed-in user. Only the
+// fields present in the request are changed. Changing the email address or password
+// needs the current password as well.
+func (app *Application) UpdateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON from the response body
 	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+		Handle          *string `json:"handle"`
+		DisplayName     *string `json:"display_name"`
+		Bio             *string `json:"bio"`
+		Avatar          *string `json:"avatar"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
@@ -192,24 +308,128 @@ func (app *Application) UpdateUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Write updated user info to DB
+	// Check every field that was given so that all of the problems are reported at once
+	var update models.UserUpdate
+	fieldErrors := make(map[string]string)
+	if input.Email != nil {
+		addr, err := mail.ParseAddress(*input.Email)
+		if err != nil {
+			fieldErrors["email"] = "Not a valid email"
+		} else {
+			update.Email = &addr.Address
+		}
+	}
+	if input.Handle != nil {
+		handle, err := app.checkHandle(*input.Handle)
+		if err != nil {
+			fieldErrors["handle"] = err.Error()
+		} else {
+			update.Handle = &handle
+		}
+	}
+	if input.DisplayName != nil {
+		displayName, err := app.checkProfileText("Display name", cleanDisplayName(*input.DisplayName), maxDisplayNameLength)
+		if err != nil {
+			fieldErrors["display_name"] = err.Error()
+		} else {
+			update.DisplayName = &displayName
+		}
+	}
+	if input.Bio != nil {
+		bio, err := app.checkProfileText("Bio", *input.Bio, maxBioLength)
+		if err != nil {
+			fieldErrors["bio"] = err.Error()
+		} else {
+			update.Bio = &bio
+		}
+	}
+	if input.Avatar != nil {
+		avatar, err := validator.Avatar(*input.Avatar)
+		if err != nil {
+			fieldErrors["avatar"] = err.Error()
+		} else {
+			update.Avatar = &avatar
+		}
+	}
 	user := app.contextGetUser(r)
-	err = app.DB.UpdateUser(user.ID, input.Email, input.Password)
+	if input.Password != nil {
+		// The password can't match the email address or handle the user will have after
+		// the update
+		email, handle := user.Email, user.Handle
+		if update.Email != nil {
+			email = *update.Email
+		}
+		if update.Handle != nil {
+			handle = *update.Handle
+		}
+		err = app.PasswordPolicy.Check(*input.Password, email, handle)
+		if err != nil {
+			fieldErrors["password"] = err.Error()
+		} else {
+			update.Password = input.Password
+		}
+	}
+	if len(fieldErrors) > 0 {
+		app.errorResponse(w, http.StatusBadRequest, envelope{
+			"message": "Some fields are invalid",
+			"fields":  fieldErrors,
+		})
+		return
+	}
+
+	// Someone with a stolen access token shouldn't be able to take over the account
+	if update.Email != nil || update.Password != nil {
+		err = app.DB.CheckPassword(*user, input.CurrentPassword)
+		if err != nil {
+			app.errorResponse(w, http.StatusUnauthorized, "Current password is incorrect")
+			return
+		}
+	}
+
+	// Write updated user info to DB
+	updated, err := app.DB.UpdateUser(user.ID, update)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrEmailTaken):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		case errors.Is(err, models.ErrHandleTaken):
+			app.errorResponse(w, http.StatusConflict, err.Error())
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Problem updating user info")
+		}
+		return
+	}
+
+	// A new email address has to be verified again
+	if !updated.EmailVerified && update.Email != nil && !strings.EqualFold(user.Email, updated.Email) {
+		err = app.sendVerificationEmail(updated)
+		if err != nil {
+			log.Printf("Error sending verification email: %s", err)
+		}
+	}
+
+	counts, err := app.DB.GetFollowCounts(user.ID)
 	if err != nil {
-		app.errorResponse(w, http.StatusInternalServerError, "Problem updating user info")
+		app.serverErrorResponse(w, r)
+		return
 	}
 
 	// Send response
-	output := struct {
-		ID          int    `json:"id"`
-		Email       string `json:"email"`
-		IsChirpyRed bool   `json:"is_chirpy_red"`
-	}{
-		ID:          user.ID,
-		Email:       input.Email,
-		IsChirpyRed: user.IsChirpyRed,
+	err = app.writeJSON(w, http.StatusOK, newUserResponse(updated, counts), nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
 	}
-	err = app.writeJSON(w, http.StatusOK, output, nil)
+}
+
+func (app *Application) GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	counts, err := app.DB.GetFollowCounts(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, newUserResponse(*user, counts), nil)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
 	}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+func TestUserResponsesOmitPassword(t *testing.T) {
+	user := models.User{
+		ID:       1,
+		Email:    "user@example.com",
+		Password: "$2a$12$secrethash",
+		Handle:   "user",
+	}
+
+	cases := []struct {
+		name      string
+		response  any
+		wantEmail bool
+	}{
+		{"Test private response", newUserResponse(user, models.FollowCounts{}), true},
+		{"Test public response", newProfileResponse(user, models.FollowCounts{}), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			js, err := json.Marshal(c.response)
+			if err != nil {
+				t.Fatalf("Couldn't marshal response: %s", err)
+			}
+			if strings.Contains(string(js), "password") || strings.Contains(string(js), user.Password) {
+				t.Errorf("Response contains the password: %s", js)
+			}
+			if strings.Contains(string(js), user.Email) != c.wantEmail {
+				t.Errorf("Expected email in response to be %v\ngot %s", c.wantEmail, js)
+			}
+		})
+	}
+}
+
+func TestVerificationToken(t *testing.T) {
+	app := &Application{Config: NewApiConfig("secret", "")}
+	now := time.Now()
+	token := app.signVerificationToken(7, "user@example.com", now.Add(time.Hour))
+
+	userID, email, err := app.parseVerificationToken(token, now)
+	if err != nil {
+		t.Fatalf("Couldn't parse token: %s", err)
+	}
+	if userID != 7 || email != "user@example.com" {
+		t.Errorf("Expected user 7 and user@example.com\ngot user %d and %s", userID, email)
+	}
+
+	payload, mac, _ := strings.Cut(token, ".")
+	other := app.signVerificationToken(8, "user@example.com", now.Add(time.Hour))
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	cases := []struct {
+		name  string
+		token string
+		now   time.Time
+	}{
+		{"Test expired", token, now.Add(2 * time.Hour)},
+		{"Test swapped payload", otherPayload + "." + mac, now},
+		{"Test missing signature", payload, now},
+		{"Test other secret", (&Application{Config: NewApiConfig("other", "")}).signVerificationToken(7, "user@example.com", now.Add(time.Hour)), now},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, _, err := app.parseVerificationToken(c.token, c.now)
+			if !errors.Is(err, errInvalidVerificationToken) {
+				t.Errorf("Expected error '%v'\ngot '%v'", errInvalidVerificationToken, err)
+			}
+		})
+	}
+}
+
+func TestTwoFactorToken(t *testing.T) {
+	app := &Application{Config: NewApiConfig("secret", "")}
+	now := time.Now()
+	token := app.signTwoFactorToken(7, now.Add(twoFactorLoginExpiry))
+
+	userID, err := app.parseTwoFactorToken(token, now)
+	if err != nil {
+		t.Fatalf("Couldn't parse token: %s", err)
+	}
+	if userID != 7 {
+		t.Errorf("Expected user 7\ngot user %d", userID)
+	}
+
+	// A verification token signed with the same secret mustn't work as a login token
+	verification := app.signVerificationToken(7, "user@example.com", now.Add(time.Hour))
+
+	cases := []struct {
+		name  string
+		token string
+		now   time.Time
+	}{
+		{"Test expired", token, now.Add(twoFactorLoginExpiry)},
+		{"Test verification token", verification, now},
+		{"Test other secret", (&Application{Config: NewApiConfig("other", "")}).signTwoFactorToken(7, now.Add(time.Hour)), now},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := app.parseTwoFactorToken(c.token, c.now)
+			if !errors.Is(err, errInvalidTwoFactorToken) {
+				t.Errorf("Expected error '%v'\ngot '%v'", errInvalidTwoFactorToken, err)
+			}
+		})
+	}
+}
+
+func TestUserUpdateResponses(t *testing.T) {
+	app := newTestApp(t)
+	db := app.DB
+
+	user, err := db.CreateUser("a@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	_, err = db.CreateUser("b@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+
+	cases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		body       string
+		wantStatus int
+	}{
+		{"Test sign up with taken email", app.CreateUserHandler, `{"email": "B@example.com", "password": "password"}`, http.StatusConflict},
+		{"Test change email without current password", app.UpdateCurrentUserHandler, `{"email": "c@example.com"}`, http.StatusUnauthorized},
+		{"Test change password with wrong current password", app.UpdateCurrentUserHandler, `{"password": "new password", "current_password": "wrong"}`, http.StatusUnauthorized},
+		{"Test change to taken email", app.UpdateCurrentUserHandler, `{"email": "B@example.com", "current_password": "password"}`, http.StatusConflict},
+		{"Test change bio without current password", app.UpdateCurrentUserHandler, `{"bio": "Hello"}`, http.StatusOK},
+		{"Test change password with current password", app.UpdateCurrentUserHandler, `{"password": "new password", "current_password": "password"}`, http.StatusOK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			current, err := db.GetUserByID(user.ID)
+			if err != nil {
+				t.Fatalf("could not get user: %v", err)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPatch, "/api/users/me", strings.NewReader(c.body))
+			r = app.contextSetUser(r, &current)
+			c.handler(w, r)
+			if w.Code != c.wantStatus {
+				t.Errorf("Expected status %d\ngot %d: %s", c.wantStatus, w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/mailer"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+const (
+	emailVerificationExpiry   = 24 * time.Hour
+	minVerificationEmailDelay = time.Minute
+)
+
+var errInvalidVerificationToken = errors.New("Verification link is invalid or has expired")
+
+// Restriction is an action that can be blocked until a user verifies their email
+type Restriction string
+
+const (
+	RestrictChirp  Restriction = "chirp"
+	RestrictFollow Restriction = "follow"
+	RestrictReport Restriction = "report"
+	RestrictVote   Restriction = "vote"
+)
+
+var Restrictions = []Restriction{
+	RestrictChirp,
+	RestrictFollow,
+	RestrictReport,
+	RestrictVote,
+}
+
+// ParseRestrictions parses a comma separated list of restrictions such as "chirp,vote"
+// into the set used for Application.UnverifiedRestrictions
+func ParseRestrictions(spec string) (map[Restriction]bool, error) {
+	restrictions := make(map[Restriction]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		restriction := Restriction(name)
+		valid := false
+		for _, r := range Restrictions {
+			valid = valid || r == restriction
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown restriction '%s'", name)
+		}
+		restrictions[restriction] = true
+	}
+
+	return restrictions, nil
+}
+
+// signVerificationToken creates a token proving that the user was sent a verification
+// link for email. The token is "payload.signature" where the payload is
+// "userID:expiry:email", both base64url encoded.
+func (app *Application) signVerificationToken(userID int, email string, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%d:%d:%s", userID, expiresAt.Unix(), email)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(app.verificationMAC(payload))
+}
+
+// parseVerificationToken checks the signature and expiry of a token made by
+// signVerificationToken and returns the user ID and email address it was made for
+func (app *Application) parseVerificationToken(token string, now time.Time) (int, string, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, "", errInvalidVerificationToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return 0, "", errInvalidVerificationToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return 0, "", errInvalidVerificationToken
+	}
+	if !hmac.Equal(mac, app.verificationMAC(string(payload))) {
+		return 0, "", errInvalidVerificationToken
+	}
+
+	parts := strings.SplitN(string(payload), ":", 3)
+	if len(parts) != 3 {
+		return 0, "", errInvalidVerificationToken
+	}
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", errInvalidVerificationToken
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || !now.Before(time.Unix(expiry, 0)) {
+		return 0, "", errInvalidVerificationToken
+	}
+
+	return userID, parts[2], nil
+}
+
+// verificationMAC signs payload with a key derived from the JWT secret so that a
+// verification token can never be passed off as anything else
+func (app *Application) verificationMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(app.Config.jwtSecret))
+	mac.Write([]byte("email-verification:" + payload))
+	return mac.Sum(nil)
+}
+
+// sendVerificationEmail sends the user a link to verify their current email address.
+// The email is sent in the background so that a slow mail server doesn't hold up the
+// request.
+func (app *Application) sendVerificationEmail(user models.User) error {
+	if app.Mailer == nil {
+		return nil
+	}
+
+	now := time.Now()
+	token := app.signVerificationToken(user.ID, user.Email, now.Add(emailVerificationExpiry))
+	link := app.BaseURL + "/api/verify-email?token=" + url.QueryEscape(token)
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf("Click the link below to verify your email address:\n\n%s\n\n"+
+			"The link expires in 24 hours. If you didn't sign up for Chirpy you can ignore this email.\n", link),
+	}
+
+	err := app.DB.SetVerificationSent(user.ID, now)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		err := app.Mailer.Send(msg)
+		if err != nil {
+			log.Printf("Error sending verification email to user %d: %s", user.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+func (app *Application) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	userID, email, err := app.parseVerificationToken(r.URL.Query().Get("token"), time.Now())
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err = app.DB.MarkEmailVerified(userID, email)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUserNotExist), errors.Is(err, models.ErrEmailChanged):
+			app.errorResponse(w, http.StatusBadRequest, errInvalidVerificationToken.Error())
+		default:
+			app.serverErrorResponse(w, r)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Email address verified"}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
+
+func (app *Application) ResendVerificationEmailHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	if user.EmailVerified {
+		app.errorResponse(w, http.StatusConflict, "Email address is already verified")
+		return
+	}
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < minVerificationEmailDelay {
+		app.rateLimitExceededResponse(w, r)
+		return
+	}
+
+	err := app.sendVerificationEmail(*user)
+	if err != nil {
+		app.serverErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "Verification email sent"}, nil)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+	}
+}
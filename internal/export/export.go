@@ -0,0 +1,301 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/validator"
+)
+
+// DefaultDir is where archives are written unless told otherwise. It is outside the
+// working directory so archives can only be downloaded through the export endpoint.
+var DefaultDir = filepath.Join(os.TempDir(), "chirpy-exports")
+
+const cleanupInterval = time.Hour
+
+var ErrBusy = errors.New("Too many data exports are being built, try again later")
+
+// Service builds archives of everything stored about a user in the background. Each
+// archive is a zip file holding data.json and a copy of the user's media under media/.
+type Service struct {
+	db   *models.DB
+	root string
+	dir  string
+	jobs chan models.DataExport
+
+	// mu makes sure a user never has two exports pending at once
+	mu sync.Mutex
+}
+
+// NewService creates an export service for the media files under root and creates the
+// directory the archives are written to if it doesn't exist. Only the current user can
+// read the archives.
+func NewService(db *models.DB, root, dir string) (*Service, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("could not create export dir: %w", err)
+	}
+
+	return &Service{
+		db:   db,
+		root: root,
+		dir:  dir,
+		jobs: make(chan models.DataExport, 16),
+	}, nil
+}
+
+// Start launches n workers that build exports until ctx is cancelled. Exports left
+// pending by a previous run are queued again, and expired exports are cleaned up
+// periodically.
+func (s *Service) Start(ctx context.Context, n int) error {
+	pending, err := s.db.GetDataExports(models.ExportPending)
+	if err != nil {
+		return err
+	}
+
+	for range n {
+		go s.worker(ctx)
+	}
+	go func() {
+		for _, export := range pending {
+			select {
+			case s.jobs <- export:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go s.cleanup(ctx)
+
+	return nil
+}
+
+func (s *Service) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case export := <-s.jobs:
+			file, err := s.build(export)
+			if err != nil {
+				log.Printf("Could not build data export %d: %s", export.ID, err)
+			}
+			_, err = s.db.FinishDataExport(export.ID, file, err)
+			if err != nil {
+				log.Printf("Could not save data export %d: %s", export.ID, err)
+			}
+		}
+	}
+}
+
+func (s *Service) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			deleted, err := s.db.DeleteExpiredDataExports(now)
+			if err != nil {
+				log.Printf("Could not clean up data exports: %s", err)
+				continue
+			}
+			for _, export := range deleted {
+				if export.File != "" {
+					os.Remove(filepath.Join(s.dir, export.File))
+				}
+			}
+		}
+	}
+}
+
+// Request returns the user's current export. A new one is queued if the user doesn't
+// have one that is pending or ready to download. If the queue is full the new export is
+// marked as failed and ErrBusy is returned.
+func (s *Service) Request(userID int) (models.DataExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest, err := s.db.GetLatestDataExport(userID)
+	if err != nil && !errors.Is(err, models.ErrExportNotExist) {
+		return models.DataExport{}, err
+	}
+	if err == nil {
+		switch {
+		case latest.Status == models.ExportPending:
+			return latest, nil
+		case latest.Status == models.ExportReady && !latest.IsExpired(time.Now()):
+			return latest, nil
+		}
+	}
+
+	export, err := s.db.CreateDataExport(userID)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+	select {
+	case s.jobs <- export:
+	default:
+		_, err = s.db.FinishDataExport(export.ID, "", ErrBusy)
+		if err != nil {
+			return models.DataExport{}, err
+		}
+		return models.DataExport{}, ErrBusy
+	}
+
+	return export, nil
+}
+
+// Open opens the archive of a finished export
+func (s *Service) Open(export models.DataExport) (*os.File, error) {
+	if export.Status != models.ExportReady || export.File == "" {
+		return nil, models.ErrExportNotExist
+	}
+	return os.Open(filepath.Join(s.dir, export.File))
+}
+
+// build writes the archive for export and returns its file name in the export dir
+func (s *Service) build(export models.DataExport) (string, error) {
+	data, err := s.db.GetUserData(export.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so that a half written archive is never served
+	tmpFile, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	err = s.writeArchive(tmpFile, data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// A random suffix keeps archive names from being guessed
+	suffix := make([]byte, 8)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("export-%d-%s.zip", export.ID, hex.EncodeToString(suffix))
+	err = os.Rename(tmpFile.Name(), filepath.Join(s.dir, name))
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+func (s *Service) writeArchive(w io.Writer, data models.UserData) error {
+	zw := zip.NewWriter(w)
+
+	dataFile, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     "data.json",
+		Method:   zip.Deflate,
+		Modified: data.ExportedAt,
+	})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(dataFile)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(data)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range mediaPaths(data) {
+		err = s.addMedia(zw, rel)
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// addMedia copies the media file at rel (relative to the service root) into the
+// archive. Media that has since been removed is skipped, as is anything that isn't a
+// regular file such as a symlink pointing out of the media directory.
+func (s *Service) addMedia(zw *zip.Writer, rel string) error {
+	srcPath := filepath.Join(s.root, filepath.FromSlash(rel))
+	info, err := os.Lstat(srcPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = "media/" + rel
+	header.Method = zip.Deflate
+	dst, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, srcFile)
+	return err
+}
+
+// mediaPaths returns the distinct media used by the user that is served by this
+// server, as paths relative to the service root. Only images in the media directory are
+// included, and media hosted elsewhere is only listed in data.json.
+func mediaPaths(data models.UserData) []string {
+	var paths []string
+	seen := make(map[string]struct{})
+	add := func(media ...string) {
+		for _, m := range media {
+			rel, ok := validator.LocalMediaPath(m)
+			if !ok {
+				continue
+			}
+			if _, ok := seen[rel]; ok {
+				continue
+			}
+			seen[rel] = struct{}{}
+			paths = append(paths, rel)
+		}
+	}
+
+	add(data.Account.Avatar)
+	for _, chirp := range data.Chirps {
+		add(chirp.Media...)
+	}
+	for _, draft := range data.Drafts {
+		add(draft.Media...)
+	}
+
+	return paths
+}
@@ -0,0 +1,105 @@
+package export
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+func TestBuild(t *testing.T) {
+	root := t.TempDir()
+	db, err := models.NewDB(filepath.Join(root, "chirp_db-export.json"))
+	if err != nil {
+		t.Fatalf("could not establish database connection: %v", err)
+	}
+
+	user, err := db.CreateUser("a@example.com", "password", "alice")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	_, err = db.CreateChirp(models.Chirp{
+		Body:     "look at this",
+		AuthorID: user.ID,
+		Media: []string{
+			"/app/assets/img.png",
+			"/app/assets/img.png",
+			"/app/assets/missing.png",
+			"https://example.com/remote.png",
+			// None of these are media and must never be copied into the archive
+			"/app/chirp_db-export.json",
+			"/app/assets/../chirp_db-export.json",
+			"/app/assets/link.png",
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create chirp: %v", err)
+	}
+	err = os.Mkdir(filepath.Join(root, "assets"), 0755)
+	if err != nil {
+		t.Fatalf("could not create media dir: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(root, "assets", "img.png"), []byte("image data"), 0644)
+	if err != nil {
+		t.Fatalf("could not write media: %v", err)
+	}
+	err = os.Symlink(filepath.Join(root, "chirp_db-export.json"), filepath.Join(root, "assets", "link.png"))
+	if err != nil {
+		t.Fatalf("could not create symlink: %v", err)
+	}
+
+	s, err := NewService(db, root, filepath.Join(t.TempDir(), "exports"))
+	if err != nil {
+		t.Fatalf("could not create export service: %v", err)
+	}
+	export, err := db.CreateDataExport(user.ID)
+	if err != nil {
+		t.Fatalf("could not create export: %v", err)
+	}
+	name, err := s.build(export)
+	if err != nil {
+		t.Fatalf("could not build export: %v", err)
+	}
+
+	zr, err := zip.OpenReader(filepath.Join(s.dir, name))
+	if err != nil {
+		t.Fatalf("could not open archive: %v", err)
+	}
+	defer zr.Close()
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("could not open %s: %v", f.Name, err)
+		}
+		contents, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(contents)
+	}
+
+	if len(files) != 2 {
+		t.Errorf("Expected data.json and one media file\ngot %v", len(files))
+	}
+	if files["media/assets/img.png"] != "image data" {
+		t.Errorf("Expected media/assets/img.png to be copied")
+	}
+
+	data := files["data.json"]
+	for _, want := range []string{`"email": "a@example.com"`, `"body": "look at this"`, "https://example.com/remote.png"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("Expected data.json to contain %s", want)
+		}
+	}
+	stored, err := db.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("could not get user: %v", err)
+	}
+	if strings.Contains(data, stored.Password) || strings.Contains(data, `"password"`) {
+		t.Errorf("data.json contains the password hash")
+	}
+}
@@ -0,0 +1,280 @@
+package filter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DefaultListPath    = "profanity.txt"
+	DefaultReplacement = "****"
+)
+
+// List is a parsed word list. Words maps a lowercased word to the string it should be
+// replaced with. Allowed holds words that must never be masked even if they would
+// otherwise match an entry in Words.
+type List struct {
+	Words   map[string]string
+	Allowed map[string]struct{}
+}
+
+// DefaultList returns the word list used when no list file is configured
+func DefaultList() List {
+	return List{
+		Words: map[string]string{
+			"kerfuffle": DefaultReplacement,
+			"sharbert":  DefaultReplacement,
+			"fornax":    DefaultReplacement,
+		},
+		Allowed: map[string]struct{}{},
+	}
+}
+
+// Parse reads a word list. Each non-empty line that doesn't start with '#' is one of:
+//
+//	word               mask word with DefaultReplacement
+//	word = replacement mask word with replacement
+//	!word              never mask word
+func Parse(r io.Reader) (List, error) {
+	list := List{
+		Words:   make(map[string]string),
+		Allowed: make(map[string]struct{}),
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if allowed, ok := strings.CutPrefix(line, "!"); ok {
+			word := normalize(allowed)
+			if word == "" {
+				return List{}, fmt.Errorf("line %d: empty allowlist entry", lineNum)
+			}
+			list.Allowed[word] = struct{}{}
+			continue
+		}
+
+		word, replacement, hasReplacement := strings.Cut(line, "=")
+		word = normalize(word)
+		if word == "" {
+			return List{}, fmt.Errorf("line %d: empty word", lineNum)
+		}
+		replacement = strings.TrimSpace(replacement)
+		if !hasReplacement || replacement == "" {
+			replacement = DefaultReplacement
+		}
+		list.Words[word] = replacement
+	}
+	if err := scanner.Err(); err != nil {
+		return List{}, err
+	}
+
+	return list, nil
+}
+
+// Filter masks words from a List in text. It is safe for concurrent use and the list
+// can be swapped out while the filter is in use.
+type Filter struct {
+	path    string
+	modTime time.Time
+
+	mu   sync.RWMutex
+	list List
+}
+
+// New creates a filter that uses the given list
+func New(list List) *Filter {
+	return &Filter{list: list}
+}
+
+// Load creates a filter from the word list file at path. Use Watch to pick up changes
+// to the file without restarting the server.
+func Load(path string) (*Filter, error) {
+	f := &Filter{path: path}
+	err := f.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Reload re-reads the word list file. The current list is kept if the file can't be
+// read or parsed.
+func (f *Filter) Reload() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf("could not open word list: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("could not stat word list: %w", err)
+	}
+
+	list, err := Parse(file)
+	if err != nil {
+		return fmt.Errorf("could not parse word list: %w", err)
+	}
+
+	f.mu.Lock()
+	f.list = list
+	f.modTime = info.ModTime()
+	f.mu.Unlock()
+
+	return nil
+}
+
+// Watch polls the word list file every interval and reloads it when it changes. It
+// returns when ctx is cancelled.
+func (f *Filter) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(f.path)
+			if err != nil {
+				log.Printf("Could not stat word list: %s", err)
+				continue
+			}
+
+			f.mu.RLock()
+			changed := !info.ModTime().Equal(f.modTime)
+			f.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			err = f.Reload()
+			if err != nil {
+				log.Printf("Could not reload word list: %s", err)
+				continue
+			}
+			log.Printf("Reloaded word list from %s", f.path)
+		}
+	}
+}
+
+// Clean returns text with every listed word replaced. Matching ignores case and
+// punctuation so "Sharbert!" and "s.h.a.r.b.e.r.t" are both caught, while all of the
+// original whitespace and surrounding punctuation is preserved.
+func (f *Filter) Clean(text string) string {
+	f.mu.RLock()
+	list := f.list
+	f.mu.RUnlock()
+
+	var sb strings.Builder
+	sb.Grow(len(text))
+
+	start := -1
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				sb.WriteString(list.cleanChunk(text[start:i]))
+				start = -1
+			}
+			sb.WriteRune(r)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		sb.WriteString(list.cleanChunk(text[start:]))
+	}
+
+	return sb.String()
+}
+
+// Matches reports whether text contains any listed word
+func (f *Filter) Matches(text string) bool {
+	return f.Clean(text) != text
+}
+
+// cleanChunk masks a run of non-whitespace characters. The chunk is first checked as a
+// whole with its punctuation removed and then each run of letters within it is checked
+// on its own.
+func (l List) cleanChunk(chunk string) string {
+	word := normalize(chunk)
+	if word == "" {
+		return chunk
+	}
+	if _, ok := l.Allowed[word]; ok {
+		return chunk
+	}
+
+	if replacement, ok := l.Words[word]; ok {
+		first := strings.IndexFunc(chunk, isWordRune)
+		last := strings.LastIndexFunc(chunk, isWordRune)
+		_, size := utf8.DecodeRuneInString(chunk[last:])
+		return chunk[:first] + replacement + chunk[last+size:]
+	}
+
+	var sb strings.Builder
+	rest := chunk
+	for rest != "" {
+		first := strings.IndexFunc(rest, isWordRune)
+		if first < 0 {
+			sb.WriteString(rest)
+			break
+		}
+		sb.WriteString(rest[:first])
+		rest = rest[first:]
+
+		end := strings.IndexFunc(rest, func(r rune) bool { return !isWordRune(r) })
+		if end < 0 {
+			end = len(rest)
+		}
+
+		subword := rest[:end]
+		sb.WriteString(l.replace(subword))
+		rest = rest[end:]
+	}
+
+	return sb.String()
+}
+
+func (l List) replace(word string) string {
+	normalized := normalize(word)
+	if _, ok := l.Allowed[normalized]; ok {
+		return word
+	}
+	if replacement, ok := l.Words[normalized]; ok {
+		return replacement
+	}
+	return word
+}
+
+// normalize lowercases s and drops everything that isn't a letter or a digit
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if !isWordRune(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testList = `# test list
+kerfuffle
+fornax = [redacted]
+sharbert
+!sharbertine
+`
+
+func TestClean(t *testing.T) {
+	list, err := Parse(strings.NewReader(testList))
+	if err != nil {
+		t.Fatalf("could not parse list: %v", err)
+	}
+	f := New(list)
+
+	cases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "Test custom replacement",
+			text: "Fornax is some prime reading material",
+			want: "[redacted] is some prime reading material",
+		},
+		{
+			name: "Test punctuation around word",
+			text: "\"Sharbert!\" he said",
+			want: "\"****!\" he said",
+		},
+		{
+			name: "Test punctuation inside word",
+			text: "what a k.e.r.f.u.f.f.l.e",
+			want: "what a ****",
+		},
+		{
+			name: "Test possessive",
+			text: "that sharbert's fault",
+			want: "that ****'s fault",
+		},
+		{
+			name: "Test allowlist",
+			text: "Sharbertine is fine",
+			want: "Sharbertine is fine",
+		},
+		{
+			name: "Test whitespace preserved",
+			text: "  KERFUFFLE\t\tfornax\n",
+			want: "  ****\t\t[redacted]\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := f.Clean(c.text)
+			if got != c.want {
+				t.Errorf("Expected '%v'\ngot '%v'", c.want, got)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	err := os.WriteFile(path, []byte("kerfuffle\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write list: %v", err)
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("could not load list: %v", err)
+	}
+	if got := f.Clean("fornax"); got != "fornax" {
+		t.Errorf("Expected 'fornax'\ngot '%v'", got)
+	}
+
+	err = os.WriteFile(path, []byte("kerfuffle\nfornax\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write list: %v", err)
+	}
+	err = f.Reload()
+	if err != nil {
+		t.Fatalf("could not reload list: %v", err)
+	}
+	if got := f.Clean("fornax"); got != DefaultReplacement {
+		t.Errorf("Expected '%v'\ngot '%v'", DefaultReplacement, got)
+	}
+}
@@ -0,0 +1,119 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultOutboxDir is where OutboxMailer writes messages unless told otherwise. It is
+// outside the working directory so the messages, which hold account tokens, are never
+// served with the static files.
+var DefaultOutboxDir = filepath.Join(os.TempDir(), "chirpy-outbox")
+
+var ErrInvalidHeader = errors.New("Email headers can't contain line breaks")
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// bytes formats msg as an RFC 5322 message from the given address
+func (msg Message) bytes(from string) ([]byte, error) {
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return buf.Bytes(), nil
+}
+
+// SMTPMailer sends mail through an SMTP server. Authentication is skipped when Username
+// is empty.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(msg Message) error {
+	data, err := msg.bytes(m.From)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, m.From, []string{msg.To}, data)
+}
+
+// OutboxMailer writes each message to its own .eml file in Dir instead of sending it.
+// It's meant for local development and testing.
+type OutboxMailer struct {
+	Dir   string
+	From  string
+	count atomic.Int64
+}
+
+// NewOutboxMailer creates dir if needed and returns a mailer that writes to it. Only
+// the current user can read the messages.
+func NewOutboxMailer(dir, from string) (*OutboxMailer, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("could not create outbox directory: %w", err)
+	}
+	return &OutboxMailer{Dir: dir, From: from}, nil
+}
+
+func (m *OutboxMailer) Send(msg Message) error {
+	data, err := msg.bytes(m.From)
+	if err != nil {
+		return err
+	}
+
+	// The counter keeps names unique when messages are sent at the same instant
+	name := fmt.Sprintf("%s-%d.eml", time.Now().UTC().Format("20060102T150405.000000000"), m.count.Add(1))
+	path := filepath.Join(m.Dir, name)
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Wrote email to %s to %s", msg.To, path)
+	return nil
+}
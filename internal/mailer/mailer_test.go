@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutboxMailer(t *testing.T) {
+	m, err := NewOutboxMailer(filepath.Join(t.TempDir(), "outbox"), "chirpy@example.com")
+	if err != nil {
+		t.Fatalf("Couldn't create outbox: %s", err)
+	}
+
+	for range 2 {
+		err = m.Send(Message{To: "user@example.com", Subject: "Héllo", Body: "line one\nline two"})
+		if err != nil {
+			t.Fatalf("Couldn't send message: %s", err)
+		}
+	}
+
+	files, err := os.ReadDir(m.Dir)
+	if err != nil {
+		t.Fatalf("Couldn't read outbox: %s", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 messages in outbox\ngot %d", len(files))
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.Dir, files[0].Name()))
+	if err != nil {
+		t.Fatalf("Couldn't read message: %s", err)
+	}
+	for _, want := range []string{
+		"From: chirpy@example.com\r\n",
+		"To: user@example.com\r\n",
+		"Subject: =?utf-8?q?H=C3=A9llo?=\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected message to contain %q\ngot %q", want, data)
+		}
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	m, err := NewOutboxMailer(t.TempDir(), "chirpy@example.com")
+	if err != nil {
+		t.Fatalf("Couldn't create outbox: %s", err)
+	}
+
+	err = m.Send(Message{To: "user@example.com\r\nBcc: victim@example.com", Subject: "Hi"})
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("Expected error '%v'\ngot '%v'", ErrInvalidHeader, err)
+	}
+}
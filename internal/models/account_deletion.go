@@ -0,0 +1,224 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrDeletionNotScheduled = errors.New("Account is not scheduled for deletion")
+
+// ScheduleUserDeletion marks the user's account to be deleted at the given time and
+// revokes their refresh tokens. Until then the deletion can be cancelled with
+// CancelUserDeletion.
+func (db *DB) ScheduleUserDeletion(id int, at time.Time) (User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return User{}, err
+	}
+
+	user, ok := dbStruct.Users[id]
+	if !ok {
+		return User{}, ErrUserNotExist
+	}
+
+	at = at.UTC()
+	user.DeletionScheduledAt = &at
+	dbStruct.Users[id] = user
+	for tokenID, token := range dbStruct.Tokens {
+		if token.UserID == id {
+			delete(dbStruct.Tokens, tokenID)
+		}
+	}
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// CancelUserDeletion stops a scheduled deletion of the user's account
+func (db *DB) CancelUserDeletion(id int) (User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return User{}, err
+	}
+
+	user, ok := dbStruct.Users[id]
+	if !ok {
+		return User{}, ErrUserNotExist
+	}
+	if user.DeletionScheduledAt == nil {
+		return User{}, ErrDeletionNotScheduled
+	}
+
+	user.DeletionScheduledAt = nil
+	dbStruct.Users[id] = user
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// DeleteUser deletes the user's account straight away along with everything that
+// belongs to it. See purgeUser for the details.
+func (db *DB) DeleteUser(id int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := dbStruct.Users[id]; !ok {
+		return ErrUserNotExist
+	}
+	purgeUser(&dbStruct, id, time.Now())
+
+	return db.writeDB(dbStruct)
+}
+
+// PurgeDueUsers deletes every account whose scheduled deletion time is not after now
+// and returns how many were deleted
+func (db *DB) PurgeDueUsers(now time.Time) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for id, user := range dbStruct.Users {
+		if user.DeletionScheduledAt == nil || user.DeletionScheduledAt.After(now) {
+			continue
+		}
+		purgeUser(&dbStruct, id, now)
+		purged++
+	}
+
+	if purged == 0 {
+		return 0, nil
+	}
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// purgeUser removes the user and everything they own from dbStruct. Chirps, drafts,
+// tokens, votes, follows, blocks and mutes are deleted. Reports and moderation log
+// entries are kept for the record but no longer point at the user, except for open
+// reports against the user's chirps which have nothing left to moderate. The user's
+// handle stays reserved so that nobody can take it over to impersonate them.
+func purgeUser(dbStruct *DBStructure, id int, now time.Time) {
+	user := dbStruct.Users[id]
+	delete(dbStruct.Users, id)
+
+	// Make sure the ID isn't handed out again
+	dbStruct.LastUserID = max(dbStruct.LastUserID, id)
+
+	for chirpID, chirp := range dbStruct.Chirps {
+		if chirp.AuthorID != id {
+			continue
+		}
+		delete(dbStruct.Chirps, chirpID)
+		deletePollsForChirp(dbStruct, chirpID)
+		removeChirpFromTimelines(dbStruct, chirpID)
+	}
+	delete(dbStruct.Timelines, id)
+
+	for draftID, draft := range dbStruct.Drafts {
+		if draft.AuthorID == id {
+			delete(dbStruct.Drafts, draftID)
+		}
+	}
+	for tokenID, token := range dbStruct.Tokens {
+		if token.UserID == id {
+			delete(dbStruct.Tokens, tokenID)
+		}
+	}
+	for resetID, reset := range dbStruct.PasswordResets {
+		if reset.UserID == id {
+			delete(dbStruct.PasswordResets, resetID)
+		}
+	}
+	for voteID, vote := range dbStruct.PollVotes {
+		if vote.UserID == id {
+			delete(dbStruct.PollVotes, voteID)
+		}
+	}
+	for followID, f := range dbStruct.Follows {
+		if f.FollowerID == id || f.FolloweeID == id {
+			delete(dbStruct.Follows, followID)
+		}
+	}
+	for blockID, b := range dbStruct.Blocks {
+		if b.BlockerID == id || b.BlockedID == id {
+			delete(dbStruct.Blocks, blockID)
+		}
+	}
+	for muteID, m := range dbStruct.Mutes {
+		if m.MuterID == id || m.MutedID == id {
+			delete(dbStruct.Mutes, muteID)
+		}
+	}
+
+	for reportID, report := range dbStruct.Reports {
+		if report.AuthorID == id && report.Status == ReportOpen {
+			delete(dbStruct.Reports, reportID)
+			continue
+		}
+		if report.AuthorID == id {
+			report.AuthorID = 0
+		}
+		if report.ReporterID == id {
+			report.ReporterID = 0
+		}
+		if report.ResolvedBy == id {
+			report.ResolvedBy = 0
+		}
+		dbStruct.Reports[reportID] = report
+	}
+	for entryID, entry := range dbStruct.ModerationLog {
+		if entry.AuthorID == id {
+			entry.AuthorID = 0
+		}
+		if entry.AdminID == id {
+			entry.AdminID = 0
+		}
+		dbStruct.ModerationLog[entryID] = entry
+	}
+
+	// Old handles reserved for the user are kept for the rest of their period as well
+	for handle, reservation := range dbStruct.Handles {
+		if reservation.UserID == id {
+			reservation.UserID = 0
+			dbStruct.Handles[handle] = reservation
+		}
+	}
+	if user.Handle != "" {
+		if dbStruct.Handles == nil {
+			dbStruct.Handles = make(map[string]HandleReservation)
+		}
+		dbStruct.Handles[user.Handle] = HandleReservation{
+			Handle:    user.Handle,
+			ExpiresAt: now.Add(HandleReservationPeriod).UTC(),
+		}
+	}
+}
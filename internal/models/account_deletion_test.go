@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPurgeDueUsers(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, user := range []struct{ email, handle string }{{"a@example.com", ""}, {"b@example.com", "alice"}} {
+		_, err := db.CreateUser(user.email, "password", user.handle)
+		if err != nil {
+			t.Fatalf("could not create user: %v", err)
+		}
+	}
+	_, err := db.CreateFollow(1, 2)
+	if err != nil {
+		t.Fatalf("could not follow user: %v", err)
+	}
+	chirp, err := db.CreateChirp(Chirp{Body: "chirp", AuthorID: 2})
+	if err != nil {
+		t.Fatalf("could not create chirp: %v", err)
+	}
+	_, err = db.CreateReport(chirp.ID, 1, ReportSpam, "")
+	if err != nil {
+		t.Fatalf("could not create report: %v", err)
+	}
+
+	now := time.Now()
+	_, err = db.ScheduleUserDeletion(2, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("could not schedule deletion: %v", err)
+	}
+
+	// Nothing happens before the grace period is over
+	purged, err := db.PurgeDueUsers(now)
+	if err != nil || purged != 0 {
+		t.Fatalf("Expected no accounts to be deleted\ngot %d, %v", purged, err)
+	}
+
+	purged, err = db.PurgeDueUsers(now.Add(2 * time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("Expected 1 account to be deleted\ngot %d, %v", purged, err)
+	}
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		t.Fatalf("could not load database: %v", err)
+	}
+	if _, ok := dbStruct.Users[2]; ok {
+		t.Errorf("User was not deleted")
+	}
+	if len(dbStruct.Chirps) != 0 || len(dbStruct.Follows) != 0 || len(dbStruct.Reports) != 0 {
+		t.Errorf("Expected chirps, follows and reports to be deleted\ngot %d, %d, %d",
+			len(dbStruct.Chirps), len(dbStruct.Follows), len(dbStruct.Reports))
+	}
+	if len(dbStruct.Timelines[1]) != 0 {
+		t.Errorf("Chirp was not removed from follower's timeline")
+	}
+
+	// Neither the ID nor the handle can be reused
+	_, err = db.CreateUser("c@example.com", "password", "alice")
+	if !errors.Is(err, ErrHandleTaken) {
+		t.Errorf("Expected error '%v'\ngot '%v'", ErrHandleTaken, err)
+	}
+	user, err := db.CreateUser("c@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	if user.ID != 3 {
+		t.Errorf("Expected new user to get ID 3\ngot %d", user.ID)
+	}
+}
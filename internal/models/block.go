@@ -0,0 +1,268 @@
+package models
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrBlockSelf       = errors.New("Users can't block or mute themselves")
+	ErrAlreadyBlocked  = errors.New("User is already blocked")
+	ErrNotBlocked      = errors.New("User is not blocked")
+	ErrAlreadyMuted    = errors.New("User is already muted")
+	ErrNotMuted        = errors.New("User is not muted")
+	ErrBlockedRelation = errors.New("One of the users has blocked the other")
+)
+
+// Block hides the two users from each other in both directions
+type Block struct {
+	ID        int       `json:"id"`
+	BlockerID int       `json:"blocker_id"`
+	BlockedID int       `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Mute hides MutedID's chirps from MuterID only. MutedID isn't affected.
+type Mute struct {
+	ID        int       `json:"id"`
+	MuterID   int       `json:"muter_id"`
+	MutedID   int       `json:"muted_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateBlock makes blockerID block blockedID. Any follows between the two users are
+// removed and their chirps are dropped from each other's timelines.
+func (db *DB) CreateBlock(blockerID, blockedID int) (Block, error) {
+	if blockerID == blockedID {
+		return Block{}, ErrBlockSelf
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Block{}, err
+	}
+
+	if _, ok := dbStruct.Users[blockedID]; !ok {
+		return Block{}, ErrUserNotExist
+	}
+	for _, b := range dbStruct.Blocks {
+		if b.BlockerID == blockerID && b.BlockedID == blockedID {
+			return Block{}, ErrAlreadyBlocked
+		}
+	}
+
+	block := Block{
+		ID:        nextID(dbStruct.Blocks),
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if dbStruct.Blocks == nil {
+		dbStruct.Blocks = make(map[int]Block)
+	}
+	dbStruct.Blocks[block.ID] = block
+
+	for id, f := range dbStruct.Follows {
+		if (f.FollowerID == blockerID && f.FolloweeID == blockedID) ||
+			(f.FollowerID == blockedID && f.FolloweeID == blockerID) {
+			delete(dbStruct.Follows, id)
+		}
+	}
+	removeAuthorFromTimeline(&dbStruct, blockerID, blockedID)
+	removeAuthorFromTimeline(&dbStruct, blockedID, blockerID)
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Block{}, err
+	}
+
+	return block, nil
+}
+
+func (db *DB) DeleteBlock(blockerID, blockedID int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	for id, b := range dbStruct.Blocks {
+		if b.BlockerID == blockerID && b.BlockedID == blockedID {
+			delete(dbStruct.Blocks, id)
+			return db.writeDB(dbStruct)
+		}
+	}
+
+	return ErrNotBlocked
+}
+
+// GetBlocks returns the blocks made by userID, newest first
+func (db *DB) GetBlocks(userID int) ([]Block, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := []Block{}
+	for _, b := range dbStruct.Blocks {
+		if b.BlockerID == userID {
+			blocks = append(blocks, b)
+		}
+	}
+	slices.SortFunc(blocks, func(a, b Block) int {
+		return -cmp.Compare(a.ID, b.ID)
+	})
+
+	return blocks, nil
+}
+
+func (db *DB) CreateMute(muterID, mutedID int) (Mute, error) {
+	if muterID == mutedID {
+		return Mute{}, ErrBlockSelf
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Mute{}, err
+	}
+
+	if _, ok := dbStruct.Users[mutedID]; !ok {
+		return Mute{}, ErrUserNotExist
+	}
+	for _, m := range dbStruct.Mutes {
+		if m.MuterID == muterID && m.MutedID == mutedID {
+			return Mute{}, ErrAlreadyMuted
+		}
+	}
+
+	mute := Mute{
+		ID:        nextID(dbStruct.Mutes),
+		MuterID:   muterID,
+		MutedID:   mutedID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if dbStruct.Mutes == nil {
+		dbStruct.Mutes = make(map[int]Mute)
+	}
+	dbStruct.Mutes[mute.ID] = mute
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Mute{}, err
+	}
+
+	return mute, nil
+}
+
+func (db *DB) DeleteMute(muterID, mutedID int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	for id, m := range dbStruct.Mutes {
+		if m.MuterID == muterID && m.MutedID == mutedID {
+			delete(dbStruct.Mutes, id)
+			return db.writeDB(dbStruct)
+		}
+	}
+
+	return ErrNotMuted
+}
+
+// GetMutes returns the mutes made by userID, newest first
+func (db *DB) GetMutes(userID int) ([]Mute, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	mutes := []Mute{}
+	for _, m := range dbStruct.Mutes {
+		if m.MuterID == userID {
+			mutes = append(mutes, m)
+		}
+	}
+	slices.SortFunc(mutes, func(a, b Mute) int {
+		return -cmp.Compare(a.ID, b.ID)
+	})
+
+	return mutes, nil
+}
+
+// GetHiddenAuthors returns the IDs of users whose chirps userID shouldn't see: users
+// they blocked, users who blocked them and users they muted
+func (db *DB) GetHiddenAuthors(userID int) (map[int]struct{}, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	hidden := blockedWith(dbStruct, userID)
+	for _, m := range dbStruct.Mutes {
+		if m.MuterID == userID {
+			hidden[m.MutedID] = struct{}{}
+		}
+	}
+
+	return hidden, nil
+}
+
+// blockedWith returns the IDs of users that userID has blocked or been blocked by
+func blockedWith(dbStruct DBStructure, userID int) map[int]struct{} {
+	blocked := make(map[int]struct{})
+	for _, b := range dbStruct.Blocks {
+		switch userID {
+		case b.BlockerID:
+			blocked[b.BlockedID] = struct{}{}
+		case b.BlockedID:
+			blocked[b.BlockerID] = struct{}{}
+		}
+	}
+	return blocked
+}
+
+// isBlocked reports whether either user has blocked the other
+func isBlocked(dbStruct DBStructure, userA, userB int) bool {
+	for _, b := range dbStruct.Blocks {
+		if (b.BlockerID == userA && b.BlockedID == userB) || (b.BlockerID == userB && b.BlockedID == userA) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBlocked reports whether either user has blocked the other
+func (db *DB) IsBlocked(userA, userB int) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return false, err
+	}
+
+	return isBlocked(dbStruct, userA, userB), nil
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCreateBlock(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		_, err := db.CreateUser(email, "password", "")
+		if err != nil {
+			t.Fatalf("could not create user: %v", err)
+		}
+	}
+
+	// Users 1 and 2 follow each other and user 1 also follows user 3
+	for _, f := range [][2]int{{1, 2}, {2, 1}, {1, 3}} {
+		_, err := db.CreateFollow(f[0], f[1])
+		if err != nil {
+			t.Fatalf("could not follow user: %v", err)
+		}
+	}
+	for _, authorID := range []int{1, 2, 3} {
+		_, err := db.CreateChirp(Chirp{Body: "chirp", AuthorID: authorID})
+		if err != nil {
+			t.Fatalf("could not create chirp: %v", err)
+		}
+	}
+
+	_, err := db.CreateBlock(1, 2)
+	if err != nil {
+		t.Fatalf("could not block user: %v", err)
+	}
+
+	t.Run("Test follows removed", func(t *testing.T) {
+		cases := []struct {
+			followerID, followeeID int
+			want                   bool
+		}{
+			{1, 2, false},
+			{2, 1, false},
+			{1, 3, true},
+		}
+		for _, c := range cases {
+			following, err := db.IsFollowing(c.followerID, c.followeeID)
+			if err != nil {
+				t.Fatalf("could not check follow: %v", err)
+			}
+			if following != c.want {
+				t.Errorf("Expected %d following %d to be %v\ngot %v", c.followerID, c.followeeID, c.want, following)
+			}
+		}
+	})
+
+	t.Run("Test timeline entries removed", func(t *testing.T) {
+		all := func(Chirp) bool { return true }
+		cases := []struct {
+			userID      int
+			wantAuthors map[int]bool
+		}{
+			{1, map[int]bool{1: true, 3: true}},
+			{2, map[int]bool{2: true}},
+		}
+		for _, c := range cases {
+			chirps, _, err := db.GetTimeline(c.userID, nil, 10, all)
+			if err != nil {
+				t.Fatalf("could not get timeline: %v", err)
+			}
+			authors := make(map[int]bool)
+			for _, chirp := range chirps {
+				authors[chirp.AuthorID] = true
+			}
+			if len(authors) != len(c.wantAuthors) {
+				t.Errorf("Expected chirps by %v in user %d's timeline\ngot %v", c.wantAuthors, c.userID, authors)
+			}
+			for authorID := range c.wantAuthors {
+				if !authors[authorID] {
+					t.Errorf("Expected chirps by %v in user %d's timeline\ngot %v", c.wantAuthors, c.userID, authors)
+				}
+			}
+		}
+	})
+
+	t.Run("Test follow lists hide blocked users", func(t *testing.T) {
+		cases := []struct {
+			name      string
+			get       func(userID, viewerID, limit, offset int) ([]Follow, int, error)
+			userID    int
+			viewerID  int
+			wantTotal int
+			wantErr   error
+		}{
+			{"Followers for anonymous viewer", db.GetFollowers, 3, 0, 1, nil},
+			{"Followers for blocked viewer", db.GetFollowers, 3, 2, 0, nil},
+			{"Following of blocked user", db.GetFollowing, 1, 2, 0, ErrUserNotExist},
+			{"Following of blocking user", db.GetFollowing, 2, 1, 0, ErrUserNotExist},
+		}
+		for _, c := range cases {
+			follows, total, err := c.get(c.userID, c.viewerID, 10, 0)
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("%s: expected error '%v'\ngot '%v'", c.name, c.wantErr, err)
+			}
+			if total != c.wantTotal || len(follows) != c.wantTotal {
+				t.Errorf("%s: expected %d follows\ngot %d of %d", c.name, c.wantTotal, len(follows), total)
+			}
+		}
+	})
+
+	t.Run("Test follow after block", func(t *testing.T) {
+		for _, f := range [][2]int{{1, 2}, {2, 1}} {
+			_, err := db.CreateFollow(f[0], f[1])
+			if !errors.Is(err, ErrBlockedRelation) {
+				t.Errorf("Expected error '%v'\ngot '%v'", ErrBlockedRelation, err)
+			}
+		}
+	})
+
+	t.Run("Test block twice", func(t *testing.T) {
+		_, err := db.CreateBlock(1, 2)
+		if !errors.Is(err, ErrAlreadyBlocked) {
+			t.Errorf("Expected error '%v'\ngot '%v'", ErrAlreadyBlocked, err)
+		}
+	})
+}
@@ -5,18 +5,86 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"time"
 )
 
-var ErrChirpNotExist = errors.New("Chirp does not exist")
+var (
+	ErrChirpNotExist     = errors.New("Chirp does not exist")
+	ErrChirpRateLimited  = errors.New("Author has created too many chirps recently")
+	ErrChirpPublished    = errors.New("Chirp has already been published")
+	ErrChirpHeld         = errors.New("Chirp is being held for moderation")
+	ErrChirpNotScheduled = errors.New("Chirp is no longer scheduled")
+)
+
+type ChirpStatus string
+
+const (
+	ChirpPublished ChirpStatus = "published"
+	ChirpHeld      ChirpStatus = "held"
+	ChirpHidden    ChirpStatus = "hidden"
+	ChirpScheduled ChirpStatus = "scheduled"
+)
+
+type ChirpVisibility string
+
+const (
+	VisibilityPublic     ChirpVisibility = "public"
+	VisibilitySignedIn   ChirpVisibility = "signed_in"
+	VisibilityAuthorOnly ChirpVisibility = "author_only"
+)
+
+var ChirpVisibilities = []ChirpVisibility{
+	VisibilityPublic,
+	VisibilitySignedIn,
+	VisibilityAuthorOnly,
+}
 
 type Chirp struct {
-	ID       int    `json:"id"`
-	Body     string `json:"body"`
-	AuthorID int    `json:"author_id"`
+	ID         int                  `json:"id"`
+	Body       string               `json:"body"`
+	AuthorID   int                  `json:"author_id"`
+	Media      []string             `json:"media,omitempty"`
+	Status     ChirpStatus          `json:"status,omitempty"`
+	Visibility ChirpVisibility      `json:"visibility,omitempty"`
+	Flagged    bool                 `json:"flagged,omitempty"`
+	Moderation []ModerationDecision `json:"moderation,omitempty"`
+	PublishAt  *time.Time           `json:"publish_at,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+}
+
+// IsPublished reports whether the chirp can be shown to other users. Chirps created
+// before statuses were introduced have no status and count as published.
+func (c Chirp) IsPublished() bool {
+	return c.Status == "" || c.Status == ChirpPublished
+}
+
+// WithoutModeration returns a copy of the chirp without the details of how moderation
+// dealt with it. Only admins get to see those.
+func (c Chirp) WithoutModeration() Chirp {
+	c.Flagged = false
+	c.Moderation = nil
+	return c
 }
 
-// CreateChirp creates a new chirp and saves it to disk
-func (db *DB) CreateChirp(body string, authorID int) (Chirp, error) {
+// IsVisibleTo reports whether the chirp's visibility setting allows user (which may be
+// nil for anonymous requests) to see it. Chirps created before visibility settings were
+// introduced are public.
+func (c Chirp) IsVisibleTo(user *User) bool {
+	switch c.Visibility {
+	case VisibilitySignedIn:
+		return user != nil
+	case VisibilityAuthorOnly:
+		return user != nil && user.ID == c.AuthorID
+	default:
+		return true
+	}
+}
+
+// CreateChirp assigns the next ID and creation time to chirp and saves it to disk.
+// ErrChirpRateLimited is returned if the author has used up their tier's chirps for
+// the current ChirpRateWindow.
+func (db *DB) CreateChirp(chirp Chirp) (Chirp, error) {
 	// Lock db and defer unlocking
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -27,6 +95,29 @@ func (db *DB) CreateChirp(body string, authorID int) (Chirp, error) {
 		return Chirp{}, err
 	}
 
+	// Write chirp to disk
+	chirp, err = insertChirp(&dbStruct, chirp)
+	if err != nil {
+		return Chirp{}, err
+	}
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+// insertChirp adds chirp to dbStruct with the next available ID. It doesn't write
+// anything to disk so that it can be used as part of a larger change. The author's rate
+// limit is checked here so that the count and the insert happen under the same lock.
+func insertChirp(dbStruct *DBStructure, chirp Chirp) (Chirp, error) {
+	now := time.Now().UTC()
+	policy := dbStruct.Users[chirp.AuthorID].Policy()
+	if countChirpsByAuthorSince(dbStruct, chirp.AuthorID, now.Add(-ChirpRateWindow)) >= policy.MaxChirpsPerWindow {
+		return Chirp{}, ErrChirpRateLimited
+	}
+
 	// Get the last ID (i.e., the largest ID)
 	var chirps []Chirp
 	lastID := 0
@@ -43,23 +134,31 @@ func (db *DB) CreateChirp(body string, authorID int) (Chirp, error) {
 		lastID = chirps[0].ID
 	}
 
+	// IDs of deleted chirps aren't reused so that reports and the moderation log never
+	// point at the wrong chirp
+	lastID = max(lastID, dbStruct.LastChirpID)
+
 	// Create chirp
 	lastID++
-	chirp := Chirp{
-		ID:       lastID,
-		Body:     body,
-		AuthorID: authorID,
+	if len(chirp.Media) == 0 {
+		chirp.Media = nil
+	}
+	if chirp.Status == "" {
+		chirp.Status = ChirpPublished
+	}
+	if chirp.Visibility == "" {
+		chirp.Visibility = VisibilityPublic
 	}
+	chirp.ID = lastID
+	chirp.CreatedAt = now
+	chirp.UpdatedAt = now
 
-	// Write chirp to disk
 	if len(dbStruct.Chirps) == 0 {
 		dbStruct.Chirps = make(map[int]Chirp)
 	}
 	dbStruct.Chirps[lastID] = chirp
-	err = db.writeDB(dbStruct)
-	if err != nil {
-		return Chirp{}, err
-	}
+	dbStruct.LastChirpID = lastID
+	fanOutChirp(dbStruct, chirp)
 
 	return chirp, nil
 }
@@ -121,8 +220,7 @@ func (db *DB) DeleteChirpByID(id int) error {
 		return ErrChirpNotExist
 	}
 
-	delete(dbStruct.Chirps, id)
-
+	deleteChirp(&dbStruct, id, time.Now().UTC())
 	err = db.writeDB(dbStruct)
 	if err != nil {
 		return err
@@ -130,3 +228,120 @@ func (db *DB) DeleteChirpByID(id int) error {
 
 	return nil
 }
+
+// CancelScheduledChirp deletes one of the author's scheduled chirps. The status is
+// checked under the same lock as the delete so that the scheduler can't publish the
+// chirp in between.
+func (db *DB) CancelScheduledChirp(id, authorID int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	chirp, ok := dbStruct.Chirps[id]
+	if !ok || chirp.AuthorID != authorID || chirp.PublishAt == nil {
+		return ErrChirpNotExist
+	}
+	switch chirp.Status {
+	case ChirpScheduled:
+	case ChirpPublished:
+		return ErrChirpPublished
+	case ChirpHeld:
+		return ErrChirpHeld
+	default:
+		return ErrChirpNotScheduled
+	}
+
+	deleteChirp(&dbStruct, id, time.Now().UTC())
+	return db.writeDB(dbStruct)
+}
+
+// deleteChirp removes a chirp along with its poll and timeline entries, and closes the
+// reports against it. It doesn't write anything to disk so that it can be used as part
+// of a larger change.
+func deleteChirp(dbStruct *DBStructure, id int, now time.Time) {
+	delete(dbStruct.Chirps, id)
+	deletePollsForChirp(dbStruct, id)
+	removeChirpFromTimelines(dbStruct, id)
+	closeReportsForChirp(dbStruct, id, now)
+}
+
+// UpdateChirp saves changes to an existing chirp. The ID and creation time can't be
+// changed. Published chirps are added to any timelines they are now visible in, such as
+// followers' timelines when a chirp only the author could see is made public.
+func (db *DB) UpdateChirp(chirp Chirp) (Chirp, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	existing, ok := dbStruct.Chirps[chirp.ID]
+	if !ok {
+		return Chirp{}, ErrChirpNotExist
+	}
+
+	chirp.CreatedAt = existing.CreatedAt
+	chirp.UpdatedAt = time.Now().UTC()
+	dbStruct.Chirps[chirp.ID] = chirp
+	fanOutChirp(&dbStruct, chirp)
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+// countChirpsByAuthorSince returns the number of chirps the author has created after
+// since
+func countChirpsByAuthorSince(dbStruct *DBStructure, authorID int, since time.Time) int {
+	count := 0
+	for _, chirp := range dbStruct.Chirps {
+		if chirp.AuthorID == authorID && chirp.CreatedAt.After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// PublishDueChirps publishes every scheduled chirp whose publish time is not after now
+// and returns how many chirps were published
+func (db *DB) PublishDueChirps(now time.Time) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for id, chirp := range dbStruct.Chirps {
+		if chirp.Status != ChirpScheduled || chirp.PublishAt == nil || chirp.PublishAt.After(now) {
+			continue
+		}
+		chirp.Status = ChirpPublished
+		chirp.UpdatedAt = now.UTC()
+		dbStruct.Chirps[id] = chirp
+		fanOutChirp(&dbStruct, chirp)
+		published++
+	}
+
+	if published == 0 {
+		return 0, nil
+	}
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return 0, err
+	}
+
+	return published, nil
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPublishDueChirps(t *testing.T) {
+	db := newTestDB(t)
+
+	follower, err := db.CreateUser("a@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	author, err := db.CreateUser("b@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	_, err = db.CreateFollow(follower.ID, author.ID)
+	if err != nil {
+		t.Fatalf("could not follow user: %v", err)
+	}
+
+	now := time.Now().UTC()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	chirps := map[string]Chirp{}
+	for name, chirp := range map[string]Chirp{
+		"due":       {Status: ChirpScheduled, PublishAt: &past},
+		"not due":   {Status: ChirpScheduled, PublishAt: &future},
+		"held":      {Status: ChirpHeld, PublishAt: &past},
+		"published": {Status: ChirpPublished},
+	} {
+		chirp.Body = name
+		chirp.AuthorID = author.ID
+		created, err := db.CreateChirp(chirp)
+		if err != nil {
+			t.Fatalf("could not create chirp: %v", err)
+		}
+		chirps[name] = created
+	}
+
+	published, err := db.PublishDueChirps(now)
+	if err != nil {
+		t.Fatalf("could not publish chirps: %v", err)
+	}
+	if published != 1 {
+		t.Errorf("Expected 1 chirp to be published\ngot %d", published)
+	}
+
+	all := func(Chirp) bool { return true }
+	timeline, _, err := db.GetTimeline(follower.ID, nil, 10, all)
+	if err != nil {
+		t.Fatalf("could not get timeline: %v", err)
+	}
+	inTimeline := make(map[int]bool)
+	for _, chirp := range timeline {
+		inTimeline[chirp.ID] = true
+	}
+
+	cases := []struct {
+		name           string
+		chirp          string
+		wantStatus     ChirpStatus
+		wantInTimeline bool
+	}{
+		{"Test due chirp is published", "due", ChirpPublished, true},
+		{"Test chirp not due yet stays scheduled", "not due", ChirpScheduled, false},
+		{"Test held chirp stays held", "held", ChirpHeld, false},
+		{"Test published chirp is left alone", "published", ChirpPublished, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			chirp, err := db.GetChirpByID(chirps[c.chirp].ID)
+			if err != nil {
+				t.Fatalf("could not get chirp: %v", err)
+			}
+			if chirp.Status != c.wantStatus {
+				t.Errorf("Expected '%v'\ngot '%v'", c.wantStatus, chirp.Status)
+			}
+			if inTimeline[chirp.ID] != c.wantInTimeline {
+				t.Errorf("Expected in timeline to be %v\ngot %v", c.wantInTimeline, inTimeline[chirp.ID])
+			}
+		})
+	}
+
+	t.Run("Test nothing left to publish", func(t *testing.T) {
+		published, err := db.PublishDueChirps(now)
+		if err != nil || published != 0 {
+			t.Errorf("Expected 0 chirps to be published\ngot %d, '%v'", published, err)
+		}
+	})
+}
+
+func TestChirpIsVisibleTo(t *testing.T) {
+	author := &User{ID: 1}
+	other := &User{ID: 2}
+
+	cases := []struct {
+		name       string
+		visibility ChirpVisibility
+		viewer     *User
+		want       bool
+	}{
+		{"Test public chirp anonymous", VisibilityPublic, nil, true},
+		{"Test public chirp signed in", VisibilityPublic, other, true},
+		{"Test public chirp author", VisibilityPublic, author, true},
+		{"Test signed-in chirp anonymous", VisibilitySignedIn, nil, false},
+		{"Test signed-in chirp signed in", VisibilitySignedIn, other, true},
+		{"Test signed-in chirp author", VisibilitySignedIn, author, true},
+		{"Test author-only chirp anonymous", VisibilityAuthorOnly, nil, false},
+		{"Test author-only chirp signed in", VisibilityAuthorOnly, other, false},
+		{"Test author-only chirp author", VisibilityAuthorOnly, author, true},
+		{"Test chirp without visibility is public", "", nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			chirp := Chirp{AuthorID: author.ID, Visibility: c.visibility}
+			if got := chirp.IsVisibleTo(c.viewer); got != c.want {
+				t.Errorf("Expected '%v'\ngot '%v'", c.want, got)
+			}
+		})
+	}
+}
+
+func TestCancelScheduledChirp(t *testing.T) {
+	db := newTestDB(t)
+
+	future := time.Now().UTC().Add(time.Hour)
+	chirps := map[string]Chirp{}
+	for name, chirp := range map[string]Chirp{
+		"scheduled":   {Status: ChirpScheduled, PublishAt: &future},
+		"held":        {Status: ChirpHeld, PublishAt: &future},
+		"published":   {Status: ChirpPublished, PublishAt: &future},
+		"hidden":      {Status: ChirpHidden, PublishAt: &future},
+		"unscheduled": {Status: ChirpPublished},
+	} {
+		chirp.Body = name
+		chirp.AuthorID = 1
+		created, err := db.CreateChirp(chirp)
+		if err != nil {
+			t.Fatalf("could not create chirp: %v", err)
+		}
+		chirps[name] = created
+	}
+
+	cases := []struct {
+		name        string
+		chirp       string
+		authorID    int
+		wantErr     error
+		wantDeleted bool
+	}{
+		{"Test other author can't cancel", "scheduled", 2, ErrChirpNotExist, false},
+		{"Test held chirp", "held", 1, ErrChirpHeld, false},
+		{"Test published chirp", "published", 1, ErrChirpPublished, false},
+		{"Test hidden chirp", "hidden", 1, ErrChirpNotScheduled, false},
+		{"Test chirp that was never scheduled", "unscheduled", 1, ErrChirpNotExist, false},
+		{"Test scheduled chirp is deleted", "scheduled", 1, nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := db.CancelScheduledChirp(chirps[c.chirp].ID, c.authorID)
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+			_, err = db.GetChirpByID(chirps[c.chirp].ID)
+			if deleted := err != nil; deleted != c.wantDeleted {
+				t.Errorf("Expected deleted to be %v\ngot %v", c.wantDeleted, deleted)
+			}
+		})
+	}
+}
@@ -4,26 +4,50 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"sync"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/password"
 )
 
 const DBFilePath = "chirp_db.json"
 
 type DB struct {
-	path string
-	mu   sync.RWMutex
+	path   string
+	mu     sync.RWMutex
+	hasher password.Hasher
+
+	dummyMu sync.Mutex
+	dummy   string
 }
 
 type DBStructure struct {
-	Chirps map[int]Chirp `json:"chirps"`
-	Users  map[int]User  `json:"users"`
-	Tokens map[int]Token `json:"tokens"`
+	Chirps         map[int]Chirp                `json:"chirps"`
+	LastChirpID    int                          `json:"last_chirp_id,omitempty"`
+	Users          map[int]User                 `json:"users"`
+	LastUserID     int                          `json:"last_user_id,omitempty"`
+	Handles        map[string]HandleReservation `json:"handle_reservations"`
+	Tokens         map[int]Token                `json:"tokens"`
+	LastTokenID    int                          `json:"last_token_id,omitempty"`
+	PasswordResets map[int]PasswordResetToken   `json:"password_resets"`
+	Drafts         map[int]Draft                `json:"drafts"`
+	Follows        map[int]Follow               `json:"follows"`
+	Blocks         map[int]Block                `json:"blocks"`
+	Mutes          map[int]Mute                 `json:"mutes"`
+	Timelines      map[int][]TimelineEntry      `json:"timelines"`
+	Polls          map[int]Poll                 `json:"polls"`
+	PollVotes      map[int]PollVote             `json:"poll_votes"`
+	Reports        map[int]Report               `json:"reports"`
+	ModerationLog  map[int]ModerationLogEntry   `json:"moderation_log"`
+	Exports        map[int]DataExport           `json:"exports"`
+	LoginFailures  map[string]LoginFailures     `json:"login_failures"`
 }
 
 // NewDB creates a new database connection and creates a database file if it doesn't exist
 func NewDB(path string) (*DB, error) {
 	chirpDB := &DB{
-		path: path,
+		path:   path,
+		hasher: password.DefaultHasher,
 	}
 	err := chirpDB.ensureDB()
 	if err != nil {
@@ -81,3 +105,22 @@ func (db *DB) writeDB(dbStructure DBStructure) error {
 
 	return nil
 }
+
+// nextID returns the ID that should be given to the next record added to m
+func nextID[V any](m map[int]V) int {
+	lastID := 0
+	for id := range m {
+		lastID = max(lastID, id)
+	}
+	return lastID + 1
+}
+
+// sortedKeys returns the IDs in m in ascending order
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for id := range m {
+		keys = append(keys, id)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -4,9 +4,12 @@ import (
 	"cmp"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"slices"
 	"testing"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/password"
 )
 
 // Re-write the tests to use golden files since the database is really just a JSON file
@@ -14,10 +17,24 @@ import (
 const testDB = "chirp_db-test.json"
 
 var testChirps = []Chirp{
-	{1, "The first chirp", 1},
-	{2, "Another chirp", 2},
-	{5, "That was some great mac 'n cheese we had last night", 3},
-	{10, "Anyone else gotta deal with noisy neighbors. I'm losing sleep over here!", 4},
+	{ID: 1, Body: "The first chirp", AuthorID: 1},
+	{ID: 2, Body: "Another chirp", AuthorID: 2},
+	{ID: 5, Body: "That was some great mac 'n cheese we had last night", AuthorID: 3},
+	{ID: 10, Body: "Anyone else gotta deal with noisy neighbors. I'm losing sleep over here!", AuthorID: 4},
+}
+
+// newTestDB returns an empty database in a temporary directory that is removed when the
+// test finishes. Passwords are hashed with the lowest bcrypt cost to keep tests fast.
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := NewDB(filepath.Join(t.TempDir(), "chirp_db.json"))
+	if err != nil {
+		t.Fatalf("could not establish database connection: %v", err)
+	}
+	db.SetPasswordHasher(password.Hasher{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4})
+
+	return db
 }
 
 // Setup test DB and populate it with test cases
@@ -98,7 +115,7 @@ func testChirpDB_GetChirps(chirpDB *DB) func(t *testing.T) {
 func testChirpDB_CreateChirp(chirpDB *DB) func(t *testing.T) {
 	return func(t *testing.T) {
 		newChirpBody := "Have you guys checked out that new pizza place yet?"
-		chirp, err := chirpDB.CreateChirp(newChirpBody, 1)
+		chirp, err := chirpDB.CreateChirp(Chirp{Body: newChirpBody, AuthorID: 1})
 		if err != nil {
 			t.Fatalf("could not create chirp: %v", err)
 		}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// MaxDraftsPerUser limits how many drafts a single user can keep around
+const MaxDraftsPerUser = 100
+
+var (
+	ErrDraftNotExist = errors.New("Draft does not exist")
+	ErrTooManyDrafts = errors.New("Too many drafts")
+)
+
+// Draft is an unpublished chirp that only its author can see
+type Draft struct {
+	ID         int             `json:"id"`
+	AuthorID   int             `json:"author_id"`
+	Body       string          `json:"body"`
+	Media      []string        `json:"media,omitempty"`
+	Visibility ChirpVisibility `json:"visibility,omitempty"`
+	PublishAt  *time.Time      `json:"publish_at,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+func (db *DB) CreateDraft(draft Draft) (Draft, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Draft{}, err
+	}
+
+	count := 0
+	for _, d := range dbStruct.Drafts {
+		if d.AuthorID == draft.AuthorID {
+			count++
+		}
+	}
+	if count >= MaxDraftsPerUser {
+		return Draft{}, ErrTooManyDrafts
+	}
+
+	now := time.Now().UTC()
+	draft.ID = nextID(dbStruct.Drafts)
+	draft.CreatedAt = now
+	draft.UpdatedAt = now
+
+	if dbStruct.Drafts == nil {
+		dbStruct.Drafts = make(map[int]Draft)
+	}
+	dbStruct.Drafts[draft.ID] = draft
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Draft{}, err
+	}
+
+	return draft, nil
+}
+
+// GetDraftsByAuthor returns the author's drafts, most recently updated first
+func (db *DB) GetDraftsByAuthor(authorID int) ([]Draft, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	drafts := []Draft{}
+	for _, draft := range dbStruct.Drafts {
+		if draft.AuthorID == authorID {
+			drafts = append(drafts, draft)
+		}
+	}
+	slices.SortFunc(drafts, func(a, b Draft) int {
+		return -a.UpdatedAt.Compare(b.UpdatedAt)
+	})
+
+	return drafts, nil
+}
+
+// GetDraft returns the draft with the given ID if it belongs to authorID. Drafts owned
+// by other users are reported as not existing.
+func (db *DB) GetDraft(id, authorID int) (Draft, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Draft{}, err
+	}
+
+	draft, ok := dbStruct.Drafts[id]
+	if !ok || draft.AuthorID != authorID {
+		return Draft{}, ErrDraftNotExist
+	}
+
+	return draft, nil
+}
+
+// UpdateDraft replaces the contents of an existing draft
+func (db *DB) UpdateDraft(draft Draft) (Draft, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Draft{}, err
+	}
+
+	existing, ok := dbStruct.Drafts[draft.ID]
+	if !ok || existing.AuthorID != draft.AuthorID {
+		return Draft{}, ErrDraftNotExist
+	}
+
+	draft.CreatedAt = existing.CreatedAt
+	draft.UpdatedAt = time.Now().UTC()
+	dbStruct.Drafts[draft.ID] = draft
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Draft{}, err
+	}
+
+	return draft, nil
+}
+
+func (db *DB) DeleteDraft(id, authorID int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	draft, ok := dbStruct.Drafts[id]
+	if !ok || draft.AuthorID != authorID {
+		return ErrDraftNotExist
+	}
+
+	delete(dbStruct.Drafts, id)
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PublishDraft saves chirp and deletes the draft it was made from in a single write so
+// that a draft can't be published twice
+func (db *DB) PublishDraft(draftID int, chirp Chirp) (Chirp, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	draft, ok := dbStruct.Drafts[draftID]
+	if !ok || draft.AuthorID != chirp.AuthorID {
+		return Chirp{}, ErrDraftNotExist
+	}
+
+	delete(dbStruct.Drafts, draftID)
+	chirp, err = insertChirp(&dbStruct, chirp)
+	if err != nil {
+		return Chirp{}, err
+	}
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPublishDraft(t *testing.T) {
+	db := newTestDB(t)
+
+	draft, err := db.CreateDraft(Draft{AuthorID: 1, Body: "not ready yet"})
+	if err != nil {
+		t.Fatalf("could not create draft: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		draftID  int
+		authorID int
+		wantErr  error
+	}{
+		{"Test other author can't publish", draft.ID, 2, ErrDraftNotExist},
+		{"Test missing draft", draft.ID + 1, 1, ErrDraftNotExist},
+		{"Test author publishes", draft.ID, 1, nil},
+		{"Test draft can't be published twice", draft.ID, 1, ErrDraftNotExist},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			chirp, err := db.PublishDraft(c.draftID, Chirp{Body: draft.Body, AuthorID: c.authorID})
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if chirp.ID == 0 || chirp.Body != draft.Body || chirp.Status != ChirpPublished {
+				t.Errorf("Expected a published chirp with the draft's body\ngot %+v", chirp)
+			}
+		})
+	}
+
+	t.Run("Test draft deleted after publishing", func(t *testing.T) {
+		drafts, err := db.GetDraftsByAuthor(1)
+		if err != nil || len(drafts) != 0 {
+			t.Errorf("Expected no drafts\ngot %d, '%v'", len(drafts), err)
+		}
+		chirps, err := db.GetChirps()
+		if err != nil || len(chirps) != 1 {
+			t.Errorf("Expected 1 chirp\ngot %d, '%v'", len(chirps), err)
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// DataExportExpiry is how long a finished export can be downloaded for
+const DataExportExpiry = 7 * 24 * time.Hour
+
+var ErrExportNotExist = errors.New("Data export does not exist")
+
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending"
+	ExportReady   ExportStatus = "ready"
+	ExportFailed  ExportStatus = "failed"
+)
+
+// DataExport tracks an archive of a user's data being generated in the background
+type DataExport struct {
+	ID          int          `json:"id"`
+	UserID      int          `json:"user_id"`
+	Status      ExportStatus `json:"status"`
+	File        string       `json:"file,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`
+}
+
+// IsExpired reports whether a finished export can no longer be downloaded
+func (e DataExport) IsExpired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// TokenInfo describes a refresh token without giving away its value
+type TokenInfo struct {
+	ID         int       `json:"id"`
+	DeviceName string    `json:"device_name,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Expiry     time.Time `json:"expiry"`
+}
+
+// UserData is everything stored about a user, as included in their data export. Secrets
+// such as the password hash and token values are left out.
+type UserData struct {
+	Account struct {
+		ID                  int        `json:"id"`
+		Email               string     `json:"email"`
+		EmailVerified       bool       `json:"email_verified"`
+		TwoFactorEnabled    bool       `json:"two_factor_enabled"`
+		Handle              string     `json:"handle,omitempty"`
+		DisplayName         string     `json:"display_name,omitempty"`
+		Bio                 string     `json:"bio,omitempty"`
+		Avatar              string     `json:"avatar,omitempty"`
+		IsAdmin             bool       `json:"is_admin"`
+		SuspendedAt         *time.Time `json:"suspended_at,omitempty"`
+		DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	} `json:"account"`
+	Subscription struct {
+		Tier        Tier `json:"tier"`
+		IsChirpyRed bool `json:"is_chirpy_red"`
+	} `json:"subscription"`
+	Tokens          []TokenInfo         `json:"refresh_tokens"`
+	ReservedHandles []HandleReservation `json:"reserved_handles"`
+	Chirps          []Chirp             `json:"chirps"`
+	Drafts          []Draft             `json:"drafts"`
+	PollVotes       []PollVote          `json:"poll_votes"`
+	Following       []Follow            `json:"following"`
+	Followers       []Follow            `json:"followers"`
+	Blocks          []Block             `json:"blocks"`
+	Mutes           []Mute              `json:"mutes"`
+	Reports         []Report            `json:"reports_filed"`
+	ExportedAt      time.Time           `json:"exported_at"`
+}
+
+// GetUserData collects everything stored about the user from a single snapshot of the
+// database. Lists are sorted by ID so that exports are stable.
+func (db *DB) GetUserData(userID int) (UserData, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return UserData{}, err
+	}
+
+	user, ok := dbStruct.Users[userID]
+	if !ok {
+		return UserData{}, ErrUserNotExist
+	}
+
+	var data UserData
+	data.Account.ID = user.ID
+	data.Account.Email = user.Email
+	data.Account.EmailVerified = user.EmailVerified
+	data.Account.TwoFactorEnabled = user.TOTPEnabled
+	data.Account.Handle = user.Handle
+	data.Account.DisplayName = user.DisplayName
+	data.Account.Bio = user.Bio
+	data.Account.Avatar = user.Avatar
+	data.Account.IsAdmin = user.IsAdmin
+	data.Account.SuspendedAt = user.SuspendedAt
+	data.Account.DeletionScheduledAt = user.DeletionScheduledAt
+	data.Subscription.Tier = user.Tier()
+	data.Subscription.IsChirpyRed = user.IsChirpyRed
+
+	for _, id := range sortedKeys(dbStruct.Tokens) {
+		if token := dbStruct.Tokens[id]; token.UserID == userID {
+			data.Tokens = append(data.Tokens, TokenInfo{
+				ID:         token.ID,
+				DeviceName: token.DeviceName,
+				IP:         token.IP,
+				CreatedAt:  token.CreatedAt,
+				LastUsedAt: token.LastUsedAt,
+				Expiry:     token.Expiry,
+			})
+		}
+	}
+	for _, reservation := range dbStruct.Handles {
+		if reservation.UserID == userID {
+			data.ReservedHandles = append(data.ReservedHandles, reservation)
+		}
+	}
+	for _, id := range sortedKeys(dbStruct.Chirps) {
+		if chirp := dbStruct.Chirps[id]; chirp.AuthorID == userID {
+			data.Chirps = append(data.Chirps, chirp.WithoutModeration())
+		}
+	}
+	for _, id := range sortedKeys(dbStruct.Drafts) {
+		if draft := dbStruct.Drafts[id]; draft.AuthorID == userID {
+			data.Drafts = append(data.Drafts, draft)
+		}
+	}
+	for _, id := range sortedKeys(dbStruct.PollVotes) {
+		if vote := dbStruct.PollVotes[id]; vote.UserID == userID {
+			data.PollVotes = append(data.PollVotes, vote)
+		}
+	}
+	for _, id := range sortedKeys(dbStruct.Follows) {
+		f := dbStruct.Follows[id]
+		if f.FollowerID == userID {
+			data.Following = append(data.Following, f)
+		}
+		if f.FolloweeID == userID {
+			data.Followers = append(data.Followers, f)
+		}
+	}
+	for _, id := range sortedKeys(dbStruct.Blocks) {
+		if b := dbStruct.Blocks[id]; b.BlockerID == userID {
+			data.Blocks = append(data.Blocks, b)
+		}
+	}
+	for _, id := range sortedKeys(dbStruct.Mutes) {
+		if m := dbStruct.Mutes[id]; m.MuterID == userID {
+			data.Mutes = append(data.Mutes, m)
+		}
+	}
+	for _, id := range sortedKeys(dbStruct.Reports) {
+		if report := dbStruct.Reports[id]; report.ReporterID == userID {
+			data.Reports = append(data.Reports, report)
+		}
+	}
+	data.ExportedAt = time.Now().UTC()
+
+	return data, nil
+}
+
+// CreateDataExport records a new pending export for the user
+func (db *DB) CreateDataExport(userID int) (DataExport, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	if _, ok := dbStruct.Users[userID]; !ok {
+		return DataExport{}, ErrUserNotExist
+	}
+
+	export := DataExport{
+		ID:        nextID(dbStruct.Exports),
+		UserID:    userID,
+		Status:    ExportPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	if dbStruct.Exports == nil {
+		dbStruct.Exports = make(map[int]DataExport)
+	}
+	dbStruct.Exports[export.ID] = export
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	return export, nil
+}
+
+// GetLatestDataExport returns the user's most recent export
+func (db *DB) GetLatestDataExport(userID int) (DataExport, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	var latest DataExport
+	for _, export := range dbStruct.Exports {
+		if export.UserID == userID && export.ID > latest.ID {
+			latest = export
+		}
+	}
+	if latest.ID == 0 {
+		return DataExport{}, ErrExportNotExist
+	}
+
+	return latest, nil
+}
+
+// GetDataExports returns every export with the given status
+func (db *DB) GetDataExports(status ExportStatus) ([]DataExport, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var exports []DataExport
+	for _, id := range sortedKeys(dbStruct.Exports) {
+		if export := dbStruct.Exports[id]; export.Status == status {
+			exports = append(exports, export)
+		}
+	}
+
+	return exports, nil
+}
+
+// FinishDataExport marks an export as ready with the archive at file, or as failed if
+// exportErr isn't nil
+func (db *DB) FinishDataExport(id int, file string, exportErr error) (DataExport, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	export, ok := dbStruct.Exports[id]
+	if !ok {
+		return DataExport{}, ErrExportNotExist
+	}
+
+	now := time.Now().UTC()
+	export.CompletedAt = &now
+	if exportErr != nil {
+		export.Status = ExportFailed
+		export.Error = exportErr.Error()
+	} else {
+		expiresAt := now.Add(DataExportExpiry)
+		export.Status = ExportReady
+		export.File = file
+		export.ExpiresAt = &expiresAt
+	}
+	dbStruct.Exports[id] = export
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	return export, nil
+}
+
+// DeleteExpiredDataExports removes exports that expired before now, along with any
+// belonging to users that no longer exist, and returns them so that their files can be
+// cleaned up
+func (db *DB) DeleteExpiredDataExports(now time.Time) ([]DataExport, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []DataExport
+	for id, export := range dbStruct.Exports {
+		_, userExists := dbStruct.Users[export.UserID]
+		if export.IsExpired(now) || !userExists {
+			delete(dbStruct.Exports, id)
+			deleted = append(deleted, export)
+		}
+	}
+
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestGetUserDataHidesModeration(t *testing.T) {
+	db := newTestDB(t)
+
+	user, err := db.CreateUser("a@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	_, err = db.CreateChirp(Chirp{
+		Body:       "chirp",
+		AuthorID:   user.ID,
+		Status:     ChirpHeld,
+		Flagged:    true,
+		Moderation: []ModerationDecision{{Stage: "keywords", Action: ModerationHold}},
+	})
+	if err != nil {
+		t.Fatalf("could not create chirp: %v", err)
+	}
+
+	data, err := db.GetUserData(user.ID)
+	if err != nil {
+		t.Fatalf("could not get user data: %v", err)
+	}
+	if len(data.Chirps) != 1 {
+		t.Fatalf("Expected 1 chirp\ngot %d", len(data.Chirps))
+	}
+
+	chirp := data.Chirps[0]
+	if chirp.Flagged || chirp.Moderation != nil {
+		t.Errorf("Expected moderation details to be left out\ngot '%v' '%v'", chirp.Flagged, chirp.Moderation)
+	}
+	if chirp.Status != ChirpHeld {
+		t.Errorf("Expected '%v'\ngot '%v'", ChirpHeld, chirp.Status)
+	}
+}
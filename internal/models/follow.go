@@ -0,0 +1,199 @@
+package models
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrFollowSelf       = errors.New("Users can't follow themselves")
+	ErrAlreadyFollowing = errors.New("User is already being followed")
+	ErrNotFollowing     = errors.New("User is not being followed")
+)
+
+// Follow is a directed edge in the follow graph: FollowerID follows FolloweeID
+type Follow struct {
+	ID         int       `json:"id"`
+	FollowerID int       `json:"follower_id"`
+	FolloweeID int       `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FollowCounts holds the number of followers a user has and how many users they follow
+type FollowCounts struct {
+	Followers int `json:"followers_count"`
+	Following int `json:"following_count"`
+}
+
+// CreateFollow makes followerID follow followeeID. Both users must exist.
+func (db *DB) CreateFollow(followerID, followeeID int) (Follow, error) {
+	if followerID == followeeID {
+		return Follow{}, ErrFollowSelf
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Follow{}, err
+	}
+
+	if _, ok := dbStruct.Users[followerID]; !ok {
+		return Follow{}, ErrUserNotExist
+	}
+	if _, ok := dbStruct.Users[followeeID]; !ok {
+		return Follow{}, ErrUserNotExist
+	}
+	if isBlocked(dbStruct, followerID, followeeID) {
+		return Follow{}, ErrBlockedRelation
+	}
+
+	for _, f := range dbStruct.Follows {
+		if f.FollowerID == followerID && f.FolloweeID == followeeID {
+			return Follow{}, ErrAlreadyFollowing
+		}
+	}
+
+	follow := Follow{
+		ID:         nextID(dbStruct.Follows),
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if dbStruct.Follows == nil {
+		dbStruct.Follows = make(map[int]Follow)
+	}
+	dbStruct.Follows[follow.ID] = follow
+	ensureTimeline(&dbStruct, followerID)
+	backfillTimeline(&dbStruct, followerID, followeeID)
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Follow{}, err
+	}
+
+	return follow, nil
+}
+
+func (db *DB) DeleteFollow(followerID, followeeID int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	for id, f := range dbStruct.Follows {
+		if f.FollowerID == followerID && f.FolloweeID == followeeID {
+			delete(dbStruct.Follows, id)
+			removeAuthorFromTimeline(&dbStruct, followerID, followeeID)
+			return db.writeDB(dbStruct)
+		}
+	}
+
+	return ErrNotFollowing
+}
+
+// GetFollowers returns a page of the follows pointing at userID, newest first, along
+// with the total number of followers, as seen by viewerID (0 for anonymous requests)
+func (db *DB) GetFollowers(userID, viewerID, limit, offset int) ([]Follow, int, error) {
+	match := func(f Follow) (bool, int) { return f.FolloweeID == userID, f.FollowerID }
+	return db.getFollows(match, userID, viewerID, limit, offset)
+}
+
+// GetFollowing returns a page of the follows made by userID, newest first, along with
+// the total number of users they follow, as seen by viewerID (0 for anonymous requests)
+func (db *DB) GetFollowing(userID, viewerID, limit, offset int) ([]Follow, int, error) {
+	match := func(f Follow) (bool, int) { return f.FollowerID == userID, f.FolloweeID }
+	return db.getFollows(match, userID, viewerID, limit, offset)
+}
+
+// getFollows returns a page of the follows that match userID. match also returns the
+// user on the other end of the follow. Users that have blocked viewerID or been blocked
+// by them are left out, and ErrUserNotExist is returned if that includes userID.
+func (db *DB) getFollows(match func(Follow) (bool, int), userID, viewerID, limit, offset int) ([]Follow, int, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, ok := dbStruct.Users[userID]; !ok {
+		return nil, 0, ErrUserNotExist
+	}
+	var hidden map[int]struct{}
+	if viewerID != 0 {
+		if isBlocked(dbStruct, viewerID, userID) {
+			return nil, 0, ErrUserNotExist
+		}
+		hidden = blockedWith(dbStruct, viewerID)
+	}
+
+	follows := []Follow{}
+	for _, f := range dbStruct.Follows {
+		ok, other := match(f)
+		if _, blocked := hidden[other]; ok && !blocked {
+			follows = append(follows, f)
+		}
+	}
+	slices.SortFunc(follows, func(a, b Follow) int {
+		return -cmp.Compare(a.ID, b.ID)
+	})
+
+	total := len(follows)
+	start := min(offset, total)
+	end := min(start+limit, total)
+
+	return follows[start:end], total, nil
+}
+
+// GetFollowCounts returns the follower and following counts for userID
+func (db *DB) GetFollowCounts(userID int) (FollowCounts, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return FollowCounts{}, err
+	}
+
+	return followCounts(dbStruct, userID), nil
+}
+
+// IsFollowing reports whether followerID follows followeeID
+func (db *DB) IsFollowing(followerID, followeeID int) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range dbStruct.Follows {
+		if f.FollowerID == followerID && f.FolloweeID == followeeID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func followCounts(dbStruct DBStructure, userID int) FollowCounts {
+	var counts FollowCounts
+	for _, f := range dbStruct.Follows {
+		if f.FolloweeID == userID {
+			counts.Followers++
+		}
+		if f.FollowerID == userID {
+			counts.Following++
+		}
+	}
+	return counts
+}
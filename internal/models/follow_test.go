@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFollow(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		_, err := db.CreateUser(email, "password", "")
+		if err != nil {
+			t.Fatalf("could not create user: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name          string
+		unfollow      bool
+		followerID    int
+		followeeID    int
+		wantErr       error
+		wantFollowing bool
+	}{
+		{"Test follow", false, 1, 2, nil, true},
+		{"Test follow twice", false, 1, 2, ErrAlreadyFollowing, true},
+		{"Test follow self", false, 1, 1, ErrFollowSelf, false},
+		{"Test follow missing user", false, 1, 3, ErrUserNotExist, false},
+		{"Test unfollow", true, 1, 2, nil, false},
+		{"Test unfollow twice", true, 1, 2, ErrNotFollowing, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var err error
+			if c.unfollow {
+				err = db.DeleteFollow(c.followerID, c.followeeID)
+			} else {
+				_, err = db.CreateFollow(c.followerID, c.followeeID)
+			}
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+
+			following, err := db.IsFollowing(c.followerID, c.followeeID)
+			if err != nil {
+				t.Fatalf("could not check follow: %v", err)
+			}
+			if following != c.wantFollowing {
+				t.Errorf("Expected following to be %v\ngot %v", c.wantFollowing, following)
+			}
+		})
+	}
+
+	t.Run("Test counts", func(t *testing.T) {
+		_, err := db.CreateFollow(2, 1)
+		if err != nil {
+			t.Fatalf("could not follow user: %v", err)
+		}
+		counts, err := db.GetFollowCounts(1)
+		if err != nil {
+			t.Fatalf("could not get counts: %v", err)
+		}
+		want := FollowCounts{Followers: 1, Following: 0}
+		if counts != want {
+			t.Errorf("Expected '%+v'\ngot '%+v'", want, counts)
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/password"
+)
+
+var ErrInvalidCredentials = errors.New("Incorrect email or password")
+
+// ThrottlePolicy decides how long login attempts are blocked for after repeated
+// failures. The first FreeAttempts failures aren't delayed, each one after that doubles
+// the delay up to MaxDelay, and LockoutAfter failures lock logins for LockoutDuration.
+// Failures are forgotten once there have been none for ForgetAfter.
+type ThrottlePolicy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	ForgetAfter     time.Duration
+}
+
+// AccountThrottlePolicy applies to failed logins for a single email address
+var AccountThrottlePolicy = ThrottlePolicy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAfter:    10,
+	LockoutDuration: 30 * time.Minute,
+	ForgetAfter:     24 * time.Hour,
+}
+
+// IPThrottlePolicy applies to failed logins from a single IP address. It is more lenient
+// than AccountThrottlePolicy since many users can share an address.
+var IPThrottlePolicy = ThrottlePolicy{
+	FreeAttempts:    10,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAfter:    100,
+	LockoutDuration: time.Hour,
+	ForgetAfter:     24 * time.Hour,
+}
+
+// blockFor returns how long logins are blocked for after the given number of failures
+func (p ThrottlePolicy) blockFor(failures int) time.Duration {
+	switch {
+	case p.LockoutAfter > 0 && failures >= p.LockoutAfter:
+		return p.LockoutDuration
+	case failures <= p.FreeAttempts:
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// LoginFailures tracks failed logins for an email address or an IP address
+type LoginFailures struct {
+	Count        int       `json:"count"`
+	LastFailedAt time.Time `json:"last_failed_at"`
+	BlockedUntil time.Time `json:"blocked_until"`
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// ReserveLoginAttempt checks whether logging in with email from ip is allowed and, if
+// it is, counts the attempt as a failure straight away. Doing both under one lock means
+// that concurrent requests can't all pass the check before any of them is counted. The
+// returned time is when logging in is allowed again, or the zero time if the attempt
+// may go ahead. Email addresses are tracked whether or not an account uses them so that
+// failures look the same either way.
+func (db *DB) ReserveLoginAttempt(email, ip string, now time.Time) (time.Time, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	keys := []string{accountThrottleKey(email), ipThrottleKey(ip)}
+	var until time.Time
+	for _, key := range keys {
+		failures := dbStruct.LoginFailures[key]
+		if failures.BlockedUntil.After(until) {
+			until = failures.BlockedUntil
+		}
+	}
+	if until.After(now) {
+		return until, nil
+	}
+
+	if dbStruct.LoginFailures == nil {
+		dbStruct.LoginFailures = make(map[string]LoginFailures)
+	}
+
+	// Drop records that have been forgotten so the table doesn't keep growing
+	for key, failures := range dbStruct.LoginFailures {
+		if now.Sub(failures.LastFailedAt) >= throttlePolicy(key).ForgetAfter && !now.Before(failures.BlockedUntil) {
+			delete(dbStruct.LoginFailures, key)
+		}
+	}
+
+	for _, key := range keys {
+		failures := dbStruct.LoginFailures[key]
+		failures.Count++
+		failures.LastFailedAt = now
+		failures.BlockedUntil = now.Add(throttlePolicy(key).blockFor(failures.Count))
+		dbStruct.LoginFailures[key] = failures
+	}
+
+	return time.Time{}, db.writeDB(dbStruct)
+}
+
+// ReleaseLoginAttempt takes back an attempt counted by ReserveLoginAttempt once it
+// turns out not to have failed, such as a correct password that still needs a second
+// factor. Use ClearLoginFailures as well once the user is fully logged in.
+func (db *DB) ReleaseLoginAttempt(email, ip string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range []string{accountThrottleKey(email), ipThrottleKey(ip)} {
+		failures, ok := dbStruct.LoginFailures[key]
+		if !ok {
+			continue
+		}
+		failures.Count--
+		if failures.Count <= 0 {
+			delete(dbStruct.LoginFailures, key)
+			continue
+		}
+		failures.BlockedUntil = failures.LastFailedAt.Add(throttlePolicy(key).blockFor(failures.Count))
+		dbStruct.LoginFailures[key] = failures
+	}
+
+	return db.writeDB(dbStruct)
+}
+
+// throttlePolicy returns the policy for a key in DBStructure.LoginFailures
+func throttlePolicy(key string) ThrottlePolicy {
+	if strings.HasPrefix(key, "ip:") {
+		return IPThrottlePolicy
+	}
+	return AccountThrottlePolicy
+}
+
+// ClearLoginFailures forgets the failed logins for email. Failures from IP addresses
+// are kept so that one working account can't be used to reset them.
+func (db *DB) ClearLoginFailures(email string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	key := accountThrottleKey(email)
+	if _, ok := dbStruct.LoginFailures[key]; !ok {
+		return nil
+	}
+	delete(dbStruct.LoginFailures, key)
+
+	return db.writeDB(dbStruct)
+}
+
+// Authenticate returns the user with the given email address if pw is their password.
+// ErrInvalidCredentials is returned for an unknown email address and for a wrong
+// password, and both take about the same time.
+func (db *DB) Authenticate(email, pw string) (User, error) {
+	user, err := db.GetUserByEmail(email)
+	if err != nil {
+		if !errors.Is(err, ErrUserNotExist) {
+			return User{}, err
+		}
+
+		hash, err := db.dummyHash()
+		if err != nil {
+			return User{}, err
+		}
+		db.hasher.Compare(hash, pw)
+		return User{}, ErrInvalidCredentials
+	}
+
+	err = db.CheckPassword(user, pw)
+	if err != nil {
+		if errors.Is(err, password.ErrMismatchedPassword) {
+			return User{}, ErrInvalidCredentials
+		}
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// dummyHash returns a hash to compare against when logging in with an unknown email
+// address so that it takes as long as a wrong password would
+func (db *DB) dummyHash() (string, error) {
+	db.dummyMu.Lock()
+	defer db.dummyMu.Unlock()
+
+	if db.dummy == "" {
+		hash, err := db.hasher.Hash("not a real password")
+		if err != nil {
+			return "", err
+		}
+		db.dummy = hash
+	}
+
+	return db.dummy, nil
+}
+
+// UnlockLogin forgets the failed logins for the user's email address so that they can
+// log in again straight away
+func (db *DB) UnlockLogin(userID int) error {
+	user, err := db.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	return db.ClearLoginFailures(user.Email)
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestThrottlePolicyBlockFor(t *testing.T) {
+	policy := ThrottlePolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        10 * time.Second,
+		LockoutAfter:    10,
+		LockoutDuration: time.Hour,
+	}
+
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{8, 10 * time.Second},
+		{10, time.Hour},
+	}
+
+	for _, c := range cases {
+		if got := policy.blockFor(c.failures); got != c.want {
+			t.Errorf("%d failures: expected %v\ngot %v", c.failures, c.want, got)
+		}
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	db := newTestDB(t)
+
+	_, err := db.CreateUser("a@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+
+	// Unknown emails and wrong passwords fail the same way
+	_, err = db.Authenticate("nobody@example.com", "password")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected error '%v'\ngot '%v'", ErrInvalidCredentials, err)
+	}
+	_, err = db.Authenticate("a@example.com", "wrong")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected error '%v'\ngot '%v'", ErrInvalidCredentials, err)
+	}
+
+	now := time.Now().UTC()
+	for range AccountThrottlePolicy.FreeAttempts + 1 {
+		until, err := db.ReserveLoginAttempt("A@example.com", "10.0.0.1", now)
+		if err != nil {
+			t.Fatalf("could not reserve login attempt: %v", err)
+		}
+		if !until.IsZero() {
+			t.Fatalf("Expected attempt to be allowed\ngot blocked until %v", until)
+		}
+	}
+
+	cases := []struct {
+		name        string
+		email       string
+		ip          string
+		wantBlocked bool
+	}{
+		{"Test email blocked from other IPs", "a@example.com", "10.0.0.2", true},
+		{"Test other emails allowed from same IP", "b@example.com", "10.0.0.1", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			until, err := db.ReserveLoginAttempt(c.email, c.ip, now)
+			if err != nil {
+				t.Fatalf("could not reserve login attempt: %v", err)
+			}
+			if until.After(now) != c.wantBlocked {
+				t.Errorf("Expected blocked to be %v\ngot blocked until %v", c.wantBlocked, until)
+			}
+		})
+	}
+
+	t.Run("Test unlocking allows logins again", func(t *testing.T) {
+		err := db.UnlockLogin(1)
+		if err != nil {
+			t.Fatalf("could not unlock login: %v", err)
+		}
+		until, err := db.ReserveLoginAttempt("a@example.com", "10.0.0.3", now)
+		if err != nil {
+			t.Fatalf("could not reserve login attempt: %v", err)
+		}
+		if until.After(now) {
+			t.Errorf("Expected logins to be allowed after unlocking")
+		}
+	})
+}
+
+func TestReserveLoginAttempt(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now().UTC()
+
+	t.Run("Test concurrent attempts can't pass the check together", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var allowed atomic.Int32
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				until, err := db.ReserveLoginAttempt("a@example.com", "10.0.0.1", now)
+				if err != nil {
+					t.Errorf("could not reserve login attempt: %v", err)
+					return
+				}
+				if until.IsZero() {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		want := int32(AccountThrottlePolicy.FreeAttempts + 1)
+		if got := allowed.Load(); got != want {
+			t.Errorf("Expected %d attempts to be allowed\ngot %d", want, got)
+		}
+	})
+
+	t.Run("Test released attempts aren't counted", func(t *testing.T) {
+		for range AccountThrottlePolicy.FreeAttempts + 3 {
+			until, err := db.ReserveLoginAttempt("b@example.com", "10.0.0.2", now)
+			if err != nil {
+				t.Fatalf("could not reserve login attempt: %v", err)
+			}
+			if !until.IsZero() {
+				t.Fatalf("Expected attempt to be allowed\ngot blocked until %v", until)
+			}
+			err = db.ReleaseLoginAttempt("b@example.com", "10.0.0.2")
+			if err != nil {
+				t.Fatalf("could not release login attempt: %v", err)
+			}
+		}
+	})
+}
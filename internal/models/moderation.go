@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type ModerationAction string
+
+const (
+	ModerationAllow  ModerationAction = "allow"
+	ModerationMask   ModerationAction = "mask"
+	ModerationReject ModerationAction = "reject"
+	ModerationHold   ModerationAction = "hold"
+	ModerationFlag   ModerationAction = "flag"
+)
+
+// ModerationDecision records what a single moderation stage decided about a chirp
+type ModerationDecision struct {
+	Stage     string           `json:"stage"`
+	Action    ModerationAction `json:"action"`
+	Reason    string           `json:"reason,omitempty"`
+	DecidedAt time.Time        `json:"decided_at"`
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	PasswordResetTokenLen = 32
+	PasswordResetExpiry   = 30 * time.Minute
+
+	// minPasswordResetDelay stops the same user being sent a flood of reset emails
+	minPasswordResetDelay = time.Minute
+)
+
+var (
+	ErrResetTokenInvalid = errors.New("Password reset token is invalid or has expired")
+	ErrResetThrottled    = errors.New("A password reset was requested too recently")
+)
+
+// PasswordResetToken lets the holder set a new password for UserID once. Only a SHA-256
+// hash of the token is stored so that the database can't be used to reset passwords.
+type PasswordResetToken struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func hashResetToken(plaintext string) string {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreatePasswordResetToken creates a reset token for the user and returns its plaintext.
+// Any earlier tokens for the user stop working.
+func (db *DB) CreatePasswordResetToken(userID int) (string, error) {
+	byteArr := make([]byte, PasswordResetTokenLen)
+	_, err := rand.Read(byteArr)
+	if err != nil {
+		return "", err
+	}
+	plaintext := hex.EncodeToString(byteArr)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return "", err
+	}
+
+	user, ok := dbStruct.Users[userID]
+	if !ok {
+		return "", ErrUserNotExist
+	}
+
+	// Clear out the user's old tokens along with any that have expired
+	now := time.Now().UTC()
+	for id, token := range dbStruct.PasswordResets {
+		if token.UserID == userID {
+			if now.Sub(token.CreatedAt) < minPasswordResetDelay {
+				return "", ErrResetThrottled
+			}
+			delete(dbStruct.PasswordResets, id)
+		} else if !now.Before(token.ExpiresAt) {
+			delete(dbStruct.PasswordResets, id)
+		}
+	}
+
+	token := PasswordResetToken{
+		ID:        nextID(dbStruct.PasswordResets),
+		UserID:    userID,
+		Email:     user.Email,
+		Hash:      hashResetToken(plaintext),
+		CreatedAt: now,
+		ExpiresAt: now.Add(PasswordResetExpiry),
+	}
+	if dbStruct.PasswordResets == nil {
+		dbStruct.PasswordResets = make(map[int]PasswordResetToken)
+	}
+	dbStruct.PasswordResets[token.ID] = token
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// GetPasswordResetUser returns the user a reset token belongs to without using the token
+// up
+func (db *DB) GetPasswordResetUser(plaintext string) (User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return User{}, err
+	}
+
+	hash := hashResetToken(plaintext)
+	for _, t := range dbStruct.PasswordResets {
+		if t.Hash != hash || !time.Now().Before(t.ExpiresAt) {
+			continue
+		}
+		user, ok := dbStruct.Users[t.UserID]
+		if !ok || !strings.EqualFold(user.Email, t.Email) {
+			break
+		}
+		return user, nil
+	}
+
+	return User{}, ErrResetTokenInvalid
+}
+
+// ResetPassword uses a reset token to set a new password. The token is used up, and all
+// of the user's refresh tokens are revoked so that any stolen sessions end. The email
+// address counts as verified since the token was sent to it.
+func (db *DB) ResetPassword(plaintext, password string) (User, error) {
+	// Hash the new password before taking the lock since it's slow
+	hashedPass, err := db.hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return User{}, err
+	}
+
+	hash := hashResetToken(plaintext)
+	var token PasswordResetToken
+	found := false
+	for _, t := range dbStruct.PasswordResets {
+		if t.Hash == hash {
+			token = t
+			found = true
+			break
+		}
+	}
+	if !found || !time.Now().Before(token.ExpiresAt) {
+		return User{}, ErrResetTokenInvalid
+	}
+
+	// A token sent to an old address can't be used once the email has changed
+	user, ok := dbStruct.Users[token.UserID]
+	if !ok || !strings.EqualFold(user.Email, token.Email) {
+		delete(dbStruct.PasswordResets, token.ID)
+		err = db.writeDB(dbStruct)
+		if err != nil {
+			return User{}, err
+		}
+		return User{}, ErrResetTokenInvalid
+	}
+
+	user.Password = hashedPass
+	user.EmailVerified = true
+	dbStruct.Users[user.ID] = user
+
+	for id, t := range dbStruct.PasswordResets {
+		if t.UserID == user.ID {
+			delete(dbStruct.PasswordResets, id)
+		}
+	}
+	for id, t := range dbStruct.Tokens {
+		if t.UserID == user.ID {
+			delete(dbStruct.Tokens, id)
+		}
+	}
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestResetPassword(t *testing.T) {
+	db := newTestDB(t)
+
+	user, err := db.CreateUser("a@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	_, err = db.CreateRefreshToken(user.ID, "", "")
+	if err != nil {
+		t.Fatalf("could not create refresh token: %v", err)
+	}
+
+	plaintext, err := db.CreatePasswordResetToken(user.ID)
+	if err != nil {
+		t.Fatalf("could not create reset token: %v", err)
+	}
+	_, err = db.CreatePasswordResetToken(user.ID)
+	if !errors.Is(err, ErrResetThrottled) {
+		t.Errorf("Expected error '%v'\ngot '%v'", ErrResetThrottled, err)
+	}
+
+	// Only the hash of the token should be stored
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		t.Fatalf("could not load database: %v", err)
+	}
+	for _, token := range dbStruct.PasswordResets {
+		if strings.Contains(token.Hash, plaintext) {
+			t.Errorf("Reset token stored in plaintext")
+		}
+	}
+
+	updated, err := db.ResetPassword(plaintext, "new password")
+	if err != nil {
+		t.Fatalf("could not reset password: %v", err)
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(updated.Password), []byte("new password"))
+	if err != nil {
+		t.Errorf("Password was not changed")
+	}
+
+	sessions, err := db.GetUserSessions(user.ID)
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("Expected refresh tokens to be revoked\ngot %d, '%v'", len(sessions), err)
+	}
+
+	_, err = db.ResetPassword(plaintext, "another password")
+	if !errors.Is(err, ErrResetTokenInvalid) {
+		t.Errorf("Expected reset token to be single use\ngot '%v'", err)
+	}
+}
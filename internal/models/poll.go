@@ -0,0 +1,203 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrPollNotExist      = errors.New("Chirp does not have a poll")
+	ErrPollClosed        = errors.New("Poll is closed")
+	ErrAlreadyVoted      = errors.New("User has already voted in this poll")
+	ErrInvalidPollOption = errors.New("Invalid poll option")
+)
+
+type PollOption struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+// Poll is attached to a single chirp. Options are numbered from 1 in the order they
+// were given.
+type Poll struct {
+	ID        int          `json:"id"`
+	ChirpID   int          `json:"chirp_id"`
+	Options   []PollOption `json:"options"`
+	ClosesAt  time.Time    `json:"closes_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+func (p Poll) IsClosed(now time.Time) bool {
+	return !now.Before(p.ClosesAt)
+}
+
+type PollVote struct {
+	ID        int       `json:"id"`
+	PollID    int       `json:"poll_id"`
+	UserID    int       `json:"user_id"`
+	OptionID  int       `json:"option_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PollSummary is a poll together with its current results. UserVote is the option the
+// requesting user voted for, or 0 if they haven't voted.
+type PollSummary struct {
+	Poll       Poll
+	Tallies    map[int]int
+	TotalVotes int
+	UserVote   int
+}
+
+// CreateChirpWithPoll saves a chirp and the poll attached to it in a single write.
+// Option IDs are assigned from the order of the options.
+func (db *DB) CreateChirpWithPoll(chirp Chirp, options []string, closesAt time.Time) (Chirp, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	chirp, err = insertChirp(&dbStruct, chirp)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	poll := Poll{
+		ID:        nextID(dbStruct.Polls),
+		ChirpID:   chirp.ID,
+		ClosesAt:  closesAt.UTC(),
+		CreatedAt: chirp.CreatedAt,
+	}
+	for i, text := range options {
+		poll.Options = append(poll.Options, PollOption{ID: i + 1, Text: text})
+	}
+
+	if dbStruct.Polls == nil {
+		dbStruct.Polls = make(map[int]Poll)
+	}
+	dbStruct.Polls[poll.ID] = poll
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+// CastVote records userID's vote for optionID in the poll attached to chirpID. Each
+// user can only vote once per poll.
+func (db *DB) CastVote(chirpID, userID, optionID int) (PollSummary, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return PollSummary{}, err
+	}
+
+	poll, ok := pollByChirpID(dbStruct, chirpID)
+	if !ok {
+		return PollSummary{}, ErrPollNotExist
+	}
+
+	now := time.Now().UTC()
+	if poll.IsClosed(now) {
+		return PollSummary{}, ErrPollClosed
+	}
+	if optionID < 1 || optionID > len(poll.Options) {
+		return PollSummary{}, ErrInvalidPollOption
+	}
+	for _, vote := range dbStruct.PollVotes {
+		if vote.PollID == poll.ID && vote.UserID == userID {
+			return PollSummary{}, ErrAlreadyVoted
+		}
+	}
+
+	vote := PollVote{
+		ID:        nextID(dbStruct.PollVotes),
+		PollID:    poll.ID,
+		UserID:    userID,
+		OptionID:  optionID,
+		CreatedAt: now,
+	}
+	if dbStruct.PollVotes == nil {
+		dbStruct.PollVotes = make(map[int]PollVote)
+	}
+	dbStruct.PollVotes[vote.ID] = vote
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return PollSummary{}, err
+	}
+
+	return summarizePolls(dbStruct, userID)[chirpID], nil
+}
+
+// GetPollSummaries returns the results of every poll keyed by the ID of the chirp it is
+// attached to. userID is used to fill in PollSummary.UserVote and may be 0.
+func (db *DB) GetPollSummaries(userID int) (map[int]PollSummary, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return summarizePolls(dbStruct, userID), nil
+}
+
+func pollByChirpID(dbStruct DBStructure, chirpID int) (Poll, bool) {
+	for _, poll := range dbStruct.Polls {
+		if poll.ChirpID == chirpID {
+			return poll, true
+		}
+	}
+	return Poll{}, false
+}
+
+func summarizePolls(dbStruct DBStructure, userID int) map[int]PollSummary {
+	summaries := make(map[int]PollSummary, len(dbStruct.Polls))
+	chirpIDs := make(map[int]int, len(dbStruct.Polls))
+	for _, poll := range dbStruct.Polls {
+		summaries[poll.ChirpID] = PollSummary{
+			Poll:    poll,
+			Tallies: make(map[int]int, len(poll.Options)),
+		}
+		chirpIDs[poll.ID] = poll.ChirpID
+	}
+
+	for _, vote := range dbStruct.PollVotes {
+		chirpID, ok := chirpIDs[vote.PollID]
+		if !ok {
+			continue
+		}
+		summary := summaries[chirpID]
+		summary.Tallies[vote.OptionID]++
+		summary.TotalVotes++
+		if userID != 0 && vote.UserID == userID {
+			summary.UserVote = vote.OptionID
+		}
+		summaries[chirpID] = summary
+	}
+
+	return summaries
+}
+
+// deletePollsForChirp removes the poll attached to a chirp along with its votes
+func deletePollsForChirp(dbStruct *DBStructure, chirpID int) {
+	for pollID, poll := range dbStruct.Polls {
+		if poll.ChirpID != chirpID {
+			continue
+		}
+		delete(dbStruct.Polls, pollID)
+		for voteID, vote := range dbStruct.PollVotes {
+			if vote.PollID == pollID {
+				delete(dbStruct.PollVotes, voteID)
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCastVote(t *testing.T) {
+	db := newTestDB(t)
+
+	open, err := db.CreateChirpWithPoll(Chirp{Body: "open", AuthorID: 1}, []string{"yes", "no"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("could not create poll: %v", err)
+	}
+	closed, err := db.CreateChirpWithPoll(Chirp{Body: "closed", AuthorID: 1}, []string{"yes", "no"}, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("could not create poll: %v", err)
+	}
+	noPoll, err := db.CreateChirp(Chirp{Body: "no poll", AuthorID: 1})
+	if err != nil {
+		t.Fatalf("could not create chirp: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		chirpID  int
+		userID   int
+		optionID int
+		wantErr  error
+	}{
+		{"Test first vote", open.ID, 2, 1, nil},
+		{"Test second vote by same user", open.ID, 2, 2, ErrAlreadyVoted},
+		{"Test vote by other user", open.ID, 3, 2, nil},
+		{"Test invalid option", open.ID, 4, 3, ErrInvalidPollOption},
+		{"Test closed poll", closed.ID, 2, 1, ErrPollClosed},
+		{"Test chirp without poll", noPoll.ID, 2, 1, ErrPollNotExist},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			summary, err := db.CastVote(c.chirpID, c.userID, c.optionID)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+			if err == nil && summary.UserVote != c.optionID {
+				t.Errorf("Expected vote for option %d\ngot %d", c.optionID, summary.UserVote)
+			}
+		})
+	}
+
+	t.Run("Test tallies", func(t *testing.T) {
+		summaries, err := db.GetPollSummaries(0)
+		if err != nil {
+			t.Fatalf("could not get polls: %v", err)
+		}
+		summary := summaries[open.ID]
+		if summary.TotalVotes != 2 || summary.Tallies[1] != 1 || summary.Tallies[2] != 1 || summary.UserVote != 0 {
+			t.Errorf("Expected one vote for each option and no user vote\ngot %+v", summary)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+)
+
+// HandleReservationPeriod is how long a handle stays reserved for its previous owner
+// after they change to a different one
+const HandleReservationPeriod = 30 * 24 * time.Hour
+
+// MaxHandleReservations is how many old handles can be reserved for a user at once. The
+// oldest reservation is released when a new one would go over the limit so that users
+// can't hoard handles by changing theirs over and over.
+const MaxHandleReservations = 3
+
+var ErrHandleTaken = errors.New("Handle is already taken")
+
+// HandleReservation keeps an old handle from being claimed by anyone but UserID until
+// ExpiresAt so that links to the old handle can't be hijacked straight away
+type HandleReservation struct {
+	Handle    string    `json:"handle"`
+	UserID    int       `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// GetUserByHandle returns the user that currently has handle. Handles are stored
+// normalized, so handle must be normalized as well.
+func (db *DB) GetUserByHandle(handle string) (User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return User{}, err
+	}
+
+	for _, user := range dbStruct.Users {
+		if handle != "" && user.Handle == handle {
+			return user, nil
+		}
+	}
+
+	return User{}, ErrUserNotExist
+}
+
+// setHandle gives user a new handle and reserves their old one for them. It doesn't
+// save user to dbStruct so that it can be used as part of a larger change.
+func setHandle(dbStruct *DBStructure, user *User, handle string, now time.Time) error {
+	if user.Handle == handle {
+		return nil
+	}
+	if !handleAvailable(*dbStruct, handle, user.ID, now) {
+		return ErrHandleTaken
+	}
+
+	// Drop expired reservations while we're here
+	for h, reservation := range dbStruct.Handles {
+		if !now.Before(reservation.ExpiresAt) {
+			delete(dbStruct.Handles, h)
+		}
+	}
+	delete(dbStruct.Handles, handle)
+
+	if user.Handle != "" {
+		if dbStruct.Handles == nil {
+			dbStruct.Handles = make(map[string]HandleReservation)
+		}
+		dbStruct.Handles[user.Handle] = HandleReservation{
+			Handle:    user.Handle,
+			UserID:    user.ID,
+			ExpiresAt: now.Add(HandleReservationPeriod).UTC(),
+		}
+		releaseOldestHandles(dbStruct, user.ID)
+	}
+
+	user.Handle = handle
+	return nil
+}
+
+// releaseOldestHandles drops the user's oldest reservations until they have at most
+// MaxHandleReservations
+func releaseOldestHandles(dbStruct *DBStructure, userID int) {
+	var reservations []HandleReservation
+	for _, reservation := range dbStruct.Handles {
+		if reservation.UserID == userID {
+			reservations = append(reservations, reservation)
+		}
+	}
+	if len(reservations) <= MaxHandleReservations {
+		return
+	}
+
+	slices.SortFunc(reservations, func(a, b HandleReservation) int {
+		if c := a.ExpiresAt.Compare(b.ExpiresAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Handle, b.Handle)
+	})
+	for _, reservation := range reservations[:len(reservations)-MaxHandleReservations] {
+		delete(dbStruct.Handles, reservation.Handle)
+	}
+}
+
+// handleAvailable reports whether userID (0 for a new user) can take handle. A handle
+// is unavailable if another user has it or it is still reserved for another user.
+func handleAvailable(dbStruct DBStructure, handle string, userID int, now time.Time) bool {
+	for _, user := range dbStruct.Users {
+		if user.Handle == handle && user.ID != userID {
+			return false
+		}
+	}
+
+	// Reservations for deleted users have a UserID of 0 and can't be claimed by anyone
+	reservation, ok := dbStruct.Handles[handle]
+	if ok && (userID == 0 || reservation.UserID != userID) && now.Before(reservation.ExpiresAt) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChangeHandle(t *testing.T) {
+	db := newTestDB(t)
+
+	user, err := db.CreateUser("a@example.com", "password", "handle0")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	other, err := db.CreateUser("b@example.com", "password", "other")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+
+	// handle0 to handle4 end up reserved for the user, but only the newest ones are kept
+	for _, handle := range []string{"handle1", "handle2", "handle3", "handle4", "handle5"} {
+		_, err := db.UpdateUser(user.ID, UserUpdate{Handle: &handle})
+		if err != nil {
+			t.Fatalf("could not change handle: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		handle  string
+		wantErr error
+	}{
+		{"Test oldest reservation released", "handle0", nil},
+		{"Test second oldest reservation released", "handle1", nil},
+		{"Test newer reservation kept", "handle2", ErrHandleTaken},
+		{"Test newest reservation kept", "handle4", ErrHandleTaken},
+		{"Test current handle taken", "handle5", ErrHandleTaken},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := db.UpdateUser(other.ID, UserUpdate{Handle: &c.handle})
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+		})
+	}
+}
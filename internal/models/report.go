@@ -0,0 +1,289 @@
+package models
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrReportNotExist      = errors.New("Report does not exist")
+	ErrReportResolved      = errors.New("Report has already been resolved")
+	ErrReportDuplicate     = errors.New("Chirp has already been reported by this user")
+	ErrInvalidReportReason = errors.New("Invalid report reason")
+	ErrInvalidReportAction = errors.New("Invalid report action")
+)
+
+type ReportReason string
+
+const (
+	ReportSpam           ReportReason = "spam"
+	ReportHarassment     ReportReason = "harassment"
+	ReportHateSpeech     ReportReason = "hate_speech"
+	ReportMisinformation ReportReason = "misinformation"
+	ReportOther          ReportReason = "other"
+	// Used for chirps that were held by the moderation pipeline rather than by a user
+	ReportModerationHold ReportReason = "moderation_hold"
+)
+
+// ReportReasons are the reasons users can give when reporting a chirp
+var ReportReasons = []ReportReason{
+	ReportSpam,
+	ReportHarassment,
+	ReportHateSpeech,
+	ReportMisinformation,
+	ReportOther,
+}
+
+type ReportStatus string
+
+const (
+	ReportOpen     ReportStatus = "open"
+	ReportResolved ReportStatus = "resolved"
+)
+
+type ReportAction string
+
+const (
+	ReportDismiss       ReportAction = "dismiss"
+	ReportHideChirp     ReportAction = "hide_chirp"
+	ReportDeleteChirp   ReportAction = "delete_chirp"
+	ReportSuspendAuthor ReportAction = "suspend_author"
+	ReportPublishChirp  ReportAction = "publish_chirp"
+)
+
+var ReportActions = []ReportAction{
+	ReportDismiss,
+	ReportHideChirp,
+	ReportDeleteChirp,
+	ReportSuspendAuthor,
+	ReportPublishChirp,
+}
+
+type Report struct {
+	ID         int          `json:"id"`
+	ChirpID    int          `json:"chirp_id"`
+	AuthorID   int          `json:"author_id"`
+	ReporterID int          `json:"reporter_id"`
+	Reason     ReportReason `json:"reason"`
+	Details    string       `json:"details,omitempty"`
+	Status     ReportStatus `json:"status"`
+	Resolution ReportAction `json:"resolution,omitempty"`
+	ResolvedBy int          `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time   `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// ModerationLogEntry records an action taken by an admin on a report
+type ModerationLogEntry struct {
+	ID        int          `json:"id"`
+	ReportID  int          `json:"report_id"`
+	ChirpID   int          `json:"chirp_id"`
+	AuthorID  int          `json:"author_id"`
+	AdminID   int          `json:"admin_id"`
+	Action    ReportAction `json:"action"`
+	Note      string       `json:"note,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// CreateReport files a report against a chirp. A user can only have one open report
+// per chirp. Automated reports use a reporterID of 0.
+func (db *DB) CreateReport(chirpID, reporterID int, reason ReportReason, details string) (Report, error) {
+	if reason != ReportModerationHold && !slices.Contains(ReportReasons, reason) {
+		return Report{}, ErrInvalidReportReason
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Report{}, err
+	}
+
+	chirp, ok := dbStruct.Chirps[chirpID]
+	if !ok {
+		return Report{}, ErrChirpNotExist
+	}
+
+	for _, report := range dbStruct.Reports {
+		if report.ChirpID == chirpID && report.ReporterID == reporterID && report.Status == ReportOpen {
+			return Report{}, ErrReportDuplicate
+		}
+	}
+
+	report := Report{
+		ID:         nextID(dbStruct.Reports),
+		ChirpID:    chirpID,
+		AuthorID:   chirp.AuthorID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		Details:    details,
+		Status:     ReportOpen,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	if dbStruct.Reports == nil {
+		dbStruct.Reports = make(map[int]Report)
+	}
+	dbStruct.Reports[report.ID] = report
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Report{}, err
+	}
+
+	return report, nil
+}
+
+// closeReportsForChirp resolves the open reports against a chirp that was deleted by
+// its author. They are kept rather than removed so that admins can still see them.
+func closeReportsForChirp(dbStruct *DBStructure, chirpID int, now time.Time) {
+	for reportID, report := range dbStruct.Reports {
+		if report.ChirpID != chirpID || report.Status != ReportOpen {
+			continue
+		}
+		report.Status = ReportResolved
+		report.Resolution = ReportDeleteChirp
+		report.ResolvedAt = &now
+		dbStruct.Reports[reportID] = report
+	}
+}
+
+// GetReports returns the reports with the given status (or all reports if status is
+// empty), oldest first
+func (db *DB) GetReports(status ReportStatus) ([]Report, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []Report{}
+	for _, report := range dbStruct.Reports {
+		if status == "" || report.Status == status {
+			reports = append(reports, report)
+		}
+	}
+	slices.SortFunc(reports, func(a, b Report) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return reports, nil
+}
+
+// ResolveReport applies action to the reported chirp or its author, closes the report
+// and records the action in the moderation log. Actions other than dismiss close every
+// open report for the same chirp. All changes are written at once.
+func (db *DB) ResolveReport(reportID, adminID int, action ReportAction, note string) (Report, error) {
+	if !slices.Contains(ReportActions, action) {
+		return Report{}, ErrInvalidReportAction
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Report{}, err
+	}
+
+	report, ok := dbStruct.Reports[reportID]
+	if !ok {
+		return Report{}, ErrReportNotExist
+	}
+	if report.Status != ReportOpen {
+		return Report{}, ErrReportResolved
+	}
+
+	// Apply the action
+	now := time.Now().UTC()
+	chirp, chirpExists := dbStruct.Chirps[report.ChirpID]
+	switch action {
+	case ReportHideChirp:
+		if chirpExists {
+			chirp.Status = ChirpHidden
+			chirp.UpdatedAt = now
+			dbStruct.Chirps[chirp.ID] = chirp
+			removeChirpFromTimelines(&dbStruct, chirp.ID)
+		}
+	case ReportPublishChirp:
+		if chirpExists {
+			chirp.Status = ChirpPublished
+			if chirp.PublishAt != nil && chirp.PublishAt.After(now) {
+				chirp.Status = ChirpScheduled
+			}
+			chirp.UpdatedAt = now
+			dbStruct.Chirps[chirp.ID] = chirp
+			fanOutChirp(&dbStruct, chirp)
+		}
+	case ReportDeleteChirp:
+		delete(dbStruct.Chirps, report.ChirpID)
+		deletePollsForChirp(&dbStruct, report.ChirpID)
+		removeChirpFromTimelines(&dbStruct, report.ChirpID)
+	case ReportSuspendAuthor:
+		author, ok := dbStruct.Users[report.AuthorID]
+		if !ok {
+			return Report{}, ErrUserNotExist
+		}
+		author.SuspendedAt = &now
+		dbStruct.Users[author.ID] = author
+	}
+
+	// Close the report along with any other reports the action also dealt with
+	for id, r := range dbStruct.Reports {
+		if id != reportID && (action == ReportDismiss || r.ChirpID != report.ChirpID || r.Status != ReportOpen) {
+			continue
+		}
+		r.Status = ReportResolved
+		r.Resolution = action
+		r.ResolvedBy = adminID
+		r.ResolvedAt = &now
+		dbStruct.Reports[id] = r
+	}
+
+	entry := ModerationLogEntry{
+		ID:        nextID(dbStruct.ModerationLog),
+		ReportID:  reportID,
+		ChirpID:   report.ChirpID,
+		AuthorID:  report.AuthorID,
+		AdminID:   adminID,
+		Action:    action,
+		Note:      note,
+		CreatedAt: now,
+	}
+	if dbStruct.ModerationLog == nil {
+		dbStruct.ModerationLog = make(map[int]ModerationLogEntry)
+	}
+	dbStruct.ModerationLog[entry.ID] = entry
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Report{}, err
+	}
+
+	return dbStruct.Reports[reportID], nil
+}
+
+// GetModerationLog returns every recorded moderation action, newest first
+func (db *DB) GetModerationLog() ([]ModerationLogEntry, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []ModerationLogEntry{}
+	for _, entry := range dbStruct.ModerationLog {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b ModerationLogEntry) int {
+		return -cmp.Compare(a.ID, b.ID)
+	})
+
+	return entries, nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestDeleteReportedChirp(t *testing.T) {
+	db := newTestDB(t)
+
+	first, err := db.CreateChirp(Chirp{Body: "first", AuthorID: 1})
+	if err != nil {
+		t.Fatalf("could not create chirp: %v", err)
+	}
+	reported, err := db.CreateChirp(Chirp{Body: "reported", AuthorID: 1})
+	if err != nil {
+		t.Fatalf("could not create chirp: %v", err)
+	}
+	kept, err := db.CreateReport(first.ID, 2, ReportSpam, "")
+	if err != nil {
+		t.Fatalf("could not create report: %v", err)
+	}
+	closed, err := db.CreateReport(reported.ID, 2, ReportSpam, "")
+	if err != nil {
+		t.Fatalf("could not create report: %v", err)
+	}
+
+	err = db.DeleteChirpByID(reported.ID)
+	if err != nil {
+		t.Fatalf("could not delete chirp: %v", err)
+	}
+	next, err := db.CreateChirp(Chirp{Body: "next", AuthorID: 1})
+	if err != nil {
+		t.Fatalf("could not create chirp: %v", err)
+	}
+
+	t.Run("Test deleted chirp ID isn't reused", func(t *testing.T) {
+		if next.ID <= reported.ID {
+			t.Errorf("Expected a chirp ID greater than %d\ngot %d", reported.ID, next.ID)
+		}
+	})
+
+	reports, err := db.GetReports("")
+	if err != nil {
+		t.Fatalf("could not get reports: %v", err)
+	}
+	reportsByID := make(map[int]Report)
+	for _, report := range reports {
+		reportsByID[report.ID] = report
+	}
+
+	cases := []struct {
+		name       string
+		reportID   int
+		status     ReportStatus
+		resolution ReportAction
+	}{
+		{"Test report against deleted chirp is closed", closed.ID, ReportResolved, ReportDeleteChirp},
+		{"Test report against other chirp stays open", kept.ID, ReportOpen, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			report := reportsByID[c.reportID]
+			if report.Status != c.status || report.Resolution != c.resolution {
+				t.Errorf("Expected '%v' '%v'\ngot '%v' '%v'", c.status, c.resolution, report.Status, report.Resolution)
+			}
+		})
+	}
+}
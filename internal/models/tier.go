@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrEditNotAllowed   = errors.New("Editing chirps requires Chirpy Red")
+	ErrEditWindowPassed = errors.New("The edit window for this chirp has passed")
+)
+
+type Tier string
+
+const (
+	TierFree      Tier = "free"
+	TierChirpyRed Tier = "chirpy_red"
+)
+
+// ChirpRateWindow is the period over which TierPolicy.MaxChirpsPerWindow is counted
+const ChirpRateWindow = 1 * time.Hour
+
+// TierPolicy holds the limits that apply to a user based on their subscription tier.
+// An EditWindow of 0 means that chirps can't be edited at all.
+type TierPolicy struct {
+	MaxChirpLength     int
+	EditWindow         time.Duration
+	MaxMediaPerChirp   int
+	MaxChirpsPerWindow int
+}
+
+var TierPolicies = map[Tier]TierPolicy{
+	TierFree: {
+		MaxChirpLength:     140,
+		EditWindow:         0,
+		MaxMediaPerChirp:   1,
+		MaxChirpsPerWindow: 30,
+	},
+	TierChirpyRed: {
+		MaxChirpLength:     500,
+		EditWindow:         15 * time.Minute,
+		MaxMediaPerChirp:   4,
+		MaxChirpsPerWindow: 120,
+	},
+}
+
+// Tier returns the subscription tier of the user
+func (u User) Tier() Tier {
+	if u.IsChirpyRed {
+		return TierChirpyRed
+	}
+	return TierFree
+}
+
+// Policy returns the limits that apply to the user
+func (u User) Policy() TierPolicy {
+	return TierPolicies[u.Tier()]
+}
+
+// CheckEdit returns an error if a chirp created at createdAt can't be edited at now
+// under the policy
+func (p TierPolicy) CheckEdit(createdAt, now time.Time) error {
+	if p.EditWindow == 0 {
+		return ErrEditNotAllowed
+	}
+	if now.Sub(createdAt) > p.EditWindow {
+		return ErrEditWindowPassed
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTierPolicies(t *testing.T) {
+	cases := []struct {
+		name string
+		user User
+		want TierPolicy
+	}{
+		{"Test free tier", User{}, TierPolicy{MaxChirpLength: 140, EditWindow: 0, MaxMediaPerChirp: 1, MaxChirpsPerWindow: 30}},
+		{"Test Chirpy Red tier", User{IsChirpyRed: true}, TierPolicy{MaxChirpLength: 500, EditWindow: 15 * time.Minute, MaxMediaPerChirp: 4, MaxChirpsPerWindow: 120}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.user.Policy(); got != c.want {
+				t.Errorf("Expected '%+v'\ngot '%+v'", c.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckEdit(t *testing.T) {
+	now := time.Now()
+	free := TierPolicies[TierFree]
+	red := TierPolicies[TierChirpyRed]
+
+	cases := []struct {
+		name    string
+		policy  TierPolicy
+		age     time.Duration
+		wantErr error
+	}{
+		{"Test free tier can't edit new chirps", free, 0, ErrEditNotAllowed},
+		{"Test Chirpy Red can edit new chirps", red, time.Minute, nil},
+		{"Test Chirpy Red can edit at end of window", red, red.EditWindow, nil},
+		{"Test Chirpy Red can't edit after window", red, red.EditWindow + time.Second, ErrEditWindowPassed},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.policy.CheckEdit(now.Add(-c.age), now)
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestChirpRateLimit(t *testing.T) {
+	db := newTestDB(t)
+
+	free, err := db.CreateUser("a@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	red, err := db.CreateUser("b@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	err = db.UpgradeChirpyRedForUser(red.ID)
+	if err != nil {
+		t.Fatalf("could not upgrade user: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		authorID int
+		limit    int
+	}{
+		{"Test free tier limit", free.ID, TierPolicies[TierFree].MaxChirpsPerWindow},
+		{"Test Chirpy Red limit", red.ID, TierPolicies[TierChirpyRed].MaxChirpsPerWindow},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for i := range c.limit {
+				_, err := db.CreateChirp(Chirp{Body: "chirp", AuthorID: c.authorID})
+				if err != nil {
+					t.Fatalf("could not create chirp %d: %v", i+1, err)
+				}
+			}
+			_, err := db.CreateChirp(Chirp{Body: "one too many", AuthorID: c.authorID})
+			if !errors.Is(err, ErrChirpRateLimited) {
+				t.Errorf("Expected error '%v'\ngot '%v'", ErrChirpRateLimited, err)
+			}
+		})
+	}
+
+	t.Run("Test concurrent chirps can't pass the limit", func(t *testing.T) {
+		author, err := db.CreateUser("c@example.com", "password", "")
+		if err != nil {
+			t.Fatalf("could not create user: %v", err)
+		}
+
+		limit := TierPolicies[TierFree].MaxChirpsPerWindow
+		var wg sync.WaitGroup
+		var created atomic.Int32
+		for range limit + 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := db.CreateChirp(Chirp{Body: "chirp", AuthorID: author.ID})
+				if err == nil {
+					created.Add(1)
+				} else if !errors.Is(err, ErrChirpRateLimited) {
+					t.Errorf("could not create chirp: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if got := created.Load(); got != int32(limit) {
+			t.Errorf("Expected %d chirps to be created\ngot %d", limit, got)
+		}
+	})
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+	"cmp"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxTimelineLength caps how many entries are kept in each user's timeline
+	MaxTimelineLength = 800
+	// timelineBackfill is how many of an author's recent chirps are added to a timeline
+	// when the author is followed
+	timelineBackfill = 50
+)
+
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// TimelineEntry points at a chirp in a user's materialised home timeline. Timelines are
+// kept sorted newest first and are updated whenever a chirp is published or deleted
+// (fan-out on write), so reading a timeline never needs to look at every chirp.
+type TimelineEntry struct {
+	ChirpID     int       `json:"chirp_id"`
+	AuthorID    int       `json:"author_id"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// TimelineCursor marks a position in a timeline. Entries at or after the cursor are
+// skipped when it is passed to GetTimeline.
+type TimelineCursor struct {
+	PublishedAt time.Time
+	ChirpID     int
+}
+
+func (c TimelineCursor) String() string {
+	raw := fmt.Sprintf("%d:%d", c.PublishedAt.UnixNano(), c.ChirpID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseTimelineCursor(s string) (TimelineCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return TimelineCursor{}, ErrInvalidCursor
+	}
+
+	nanosStr, chirpIDStr, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return TimelineCursor{}, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(nanosStr, 10, 64)
+	if err != nil {
+		return TimelineCursor{}, ErrInvalidCursor
+	}
+	chirpID, err := strconv.Atoi(chirpIDStr)
+	if err != nil {
+		return TimelineCursor{}, ErrInvalidCursor
+	}
+
+	return TimelineCursor{PublishedAt: time.Unix(0, nanos).UTC(), ChirpID: chirpID}, nil
+}
+
+// before reports whether entry comes after the cursor in newest-first order
+func (c TimelineCursor) before(entry TimelineEntry) bool {
+	if !entry.PublishedAt.Equal(c.PublishedAt) {
+		return entry.PublishedAt.Before(c.PublishedAt)
+	}
+	return entry.ChirpID < c.ChirpID
+}
+
+func compareTimelineEntries(a, b TimelineEntry) int {
+	if c := b.PublishedAt.Compare(a.PublishedAt); c != 0 {
+		return c
+	}
+	return -cmp.Compare(a.ChirpID, b.ChirpID)
+}
+
+// GetTimeline returns up to limit chirps from userID's timeline that come after cursor
+// (or from the start if cursor is nil) and pass visible. The returned cursor can be used
+// to get the next page and is nil once the end of the timeline has been reached.
+func (db *DB) GetTimeline(userID int, cursor *TimelineCursor, limit int, visible func(Chirp) bool) ([]Chirp, *TimelineCursor, error) {
+	db.mu.RLock()
+	dbStruct, err := db.loadDB()
+	db.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Users that existed before timelines were introduced don't have one yet, so theirs
+	// is built and saved the first time it is read
+	entries, ok := dbStruct.Timelines[userID]
+	if !ok {
+		dbStruct, err = db.storeTimeline(userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = dbStruct.Timelines[userID]
+	}
+
+	chirps := []Chirp{}
+	var last TimelineEntry
+	for i, entry := range entries {
+		if cursor != nil && !cursor.before(entry) {
+			continue
+		}
+
+		chirp, ok := dbStruct.Chirps[entry.ChirpID]
+		if !ok || !visible(chirp) {
+			continue
+		}
+
+		chirps = append(chirps, chirp)
+		last = entry
+		if len(chirps) == limit {
+			if i == len(entries)-1 {
+				break
+			}
+			return chirps, &TimelineCursor{PublishedAt: last.PublishedAt, ChirpID: last.ChirpID}, nil
+		}
+	}
+
+	return chirps, nil, nil
+}
+
+// buildTimeline builds userID's timeline from scratch out of their own chirps and the
+// chirps of the users they follow
+func buildTimeline(dbStruct DBStructure, userID int) []TimelineEntry {
+	authors := map[int]struct{}{userID: {}}
+	for _, f := range dbStruct.Follows {
+		if f.FollowerID == userID {
+			authors[f.FolloweeID] = struct{}{}
+		}
+	}
+
+	entries := []TimelineEntry{}
+	for _, chirp := range dbStruct.Chirps {
+		if _, ok := authors[chirp.AuthorID]; ok && chirp.IsPublished() {
+			entries = append(entries, newTimelineEntry(chirp))
+		}
+	}
+	slices.SortFunc(entries, compareTimelineEntries)
+	if len(entries) > MaxTimelineLength {
+		entries = entries[:MaxTimelineLength]
+	}
+
+	return entries
+}
+
+// storeTimeline builds and saves userID's timeline if they don't have one yet, and
+// returns the database as it was saved
+func (db *DB) storeTimeline(userID int) (DBStructure, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	if _, ok := dbStruct.Users[userID]; !ok {
+		return DBStructure{}, ErrUserNotExist
+	}
+	if _, ok := dbStruct.Timelines[userID]; ok {
+		// Someone else stored it after we last looked
+		return dbStruct, nil
+	}
+
+	ensureTimeline(&dbStruct, userID)
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	return dbStruct, nil
+}
+
+// ensureTimeline stores a timeline for userID if they don't have one yet. It doesn't
+// write anything to disk so that it can be used as part of a larger change.
+func ensureTimeline(dbStruct *DBStructure, userID int) {
+	if _, ok := dbStruct.Timelines[userID]; ok {
+		return
+	}
+	if dbStruct.Timelines == nil {
+		dbStruct.Timelines = make(map[int][]TimelineEntry)
+	}
+	dbStruct.Timelines[userID] = buildTimeline(*dbStruct, userID)
+}
+
+func newTimelineEntry(chirp Chirp) TimelineEntry {
+	publishedAt := chirp.CreatedAt
+	if chirp.PublishAt != nil {
+		publishedAt = *chirp.PublishAt
+	}
+	return TimelineEntry{
+		ChirpID:     chirp.ID,
+		AuthorID:    chirp.AuthorID,
+		PublishedAt: publishedAt,
+	}
+}
+
+// fanOutChirp adds a published chirp to the timelines of its author and their
+// followers. Chirps only the author can see are only added to the author's timeline.
+func fanOutChirp(dbStruct *DBStructure, chirp Chirp) {
+	if !chirp.IsPublished() {
+		return
+	}
+
+	entry := newTimelineEntry(chirp)
+	addTimelineEntry(dbStruct, chirp.AuthorID, entry)
+	if chirp.Visibility == VisibilityAuthorOnly {
+		return
+	}
+	for _, f := range dbStruct.Follows {
+		if f.FolloweeID == chirp.AuthorID {
+			addTimelineEntry(dbStruct, f.FollowerID, entry)
+		}
+	}
+}
+
+// addTimelineEntry inserts entry into userID's timeline, keeping it sorted and within
+// MaxTimelineLength. Timelines that haven't been built yet are left alone since they
+// will be built in full when they are first read.
+func addTimelineEntry(dbStruct *DBStructure, userID int, entry TimelineEntry) {
+	entries, ok := dbStruct.Timelines[userID]
+	if !ok {
+		return
+	}
+
+	entries = slices.DeleteFunc(entries, func(e TimelineEntry) bool {
+		return e.ChirpID == entry.ChirpID
+	})
+	i, _ := slices.BinarySearchFunc(entries, entry, compareTimelineEntries)
+	entries = slices.Insert(entries, i, entry)
+	if len(entries) > MaxTimelineLength {
+		entries = entries[:MaxTimelineLength]
+	}
+	dbStruct.Timelines[userID] = entries
+}
+
+// removeChirpFromTimelines drops a chirp from every timeline
+func removeChirpFromTimelines(dbStruct *DBStructure, chirpID int) {
+	for userID, entries := range dbStruct.Timelines {
+		dbStruct.Timelines[userID] = slices.DeleteFunc(entries, func(e TimelineEntry) bool {
+			return e.ChirpID == chirpID
+		})
+	}
+}
+
+// backfillTimeline adds the author's most recent chirps to the follower's timeline
+func backfillTimeline(dbStruct *DBStructure, followerID, authorID int) {
+	if _, ok := dbStruct.Timelines[followerID]; !ok {
+		return
+	}
+
+	var recent []TimelineEntry
+	for _, chirp := range dbStruct.Chirps {
+		if chirp.AuthorID == authorID && chirp.IsPublished() && chirp.Visibility != VisibilityAuthorOnly {
+			recent = append(recent, newTimelineEntry(chirp))
+		}
+	}
+	slices.SortFunc(recent, compareTimelineEntries)
+	if len(recent) > timelineBackfill {
+		recent = recent[:timelineBackfill]
+	}
+
+	for _, entry := range recent {
+		addTimelineEntry(dbStruct, followerID, entry)
+	}
+}
+
+// removeAuthorFromTimeline drops every chirp by authorID from the follower's timeline
+func removeAuthorFromTimeline(dbStruct *DBStructure, followerID, authorID int) {
+	entries, ok := dbStruct.Timelines[followerID]
+	if !ok {
+		return
+	}
+	dbStruct.Timelines[followerID] = slices.DeleteFunc(entries, func(e TimelineEntry) bool {
+		return e.AuthorID == authorID
+	})
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTimeline(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		_, err := db.CreateUser(email, "password", "")
+		if err != nil {
+			t.Fatalf("could not create user: %v", err)
+		}
+	}
+
+	// User 1 follows user 2 but not user 3
+	_, err := db.CreateFollow(1, 2)
+	if err != nil {
+		t.Fatalf("could not follow user: %v", err)
+	}
+	all := func(Chirp) bool { return true }
+	_, _, err = db.GetTimeline(1, nil, 10, all)
+	if err != nil {
+		t.Fatalf("could not get timeline: %v", err)
+	}
+
+	for _, authorID := range []int{1, 2, 3, 2} {
+		_, err := db.CreateChirp(Chirp{Body: "chirp", AuthorID: authorID})
+		if err != nil {
+			t.Fatalf("could not create chirp: %v", err)
+		}
+	}
+
+	timelineIDs := func(limit int, cursor *TimelineCursor) ([]int, *TimelineCursor) {
+		chirps, next, err := db.GetTimeline(1, cursor, limit, all)
+		if err != nil {
+			t.Fatalf("could not get timeline: %v", err)
+		}
+		var ids []int
+		for _, c := range chirps {
+			ids = append(ids, c.ID)
+		}
+		return ids, next
+	}
+
+	t.Run("Fan out on create", func(t *testing.T) {
+		ids, next := timelineIDs(10, nil)
+		if len(ids) != 3 || ids[0] != 4 || ids[1] != 2 || ids[2] != 1 || next != nil {
+			t.Errorf("Expected [4 2 1] and no cursor\ngot %v %v", ids, next)
+		}
+	})
+
+	t.Run("Cursor pagination", func(t *testing.T) {
+		ids, next := timelineIDs(2, nil)
+		if len(ids) != 2 || next == nil {
+			t.Fatalf("Expected 2 chirps and a cursor\ngot %v %v", ids, next)
+		}
+		parsed, err := ParseTimelineCursor(next.String())
+		if err != nil {
+			t.Fatalf("could not parse cursor: %v", err)
+		}
+		ids, next = timelineIDs(2, &parsed)
+		if len(ids) != 1 || ids[0] != 1 || next != nil {
+			t.Errorf("Expected [1] and no cursor\ngot %v %v", ids, next)
+		}
+	})
+
+	t.Run("Remove on delete", func(t *testing.T) {
+		err := db.DeleteChirpByID(4)
+		if err != nil {
+			t.Fatalf("could not delete chirp: %v", err)
+		}
+		ids, _ := timelineIDs(10, nil)
+		if len(ids) != 2 || ids[0] != 2 {
+			t.Errorf("Expected [2 1]\ngot %v", ids)
+		}
+	})
+
+	t.Run("Fan out on visibility change", func(t *testing.T) {
+		chirp, err := db.CreateChirp(Chirp{Body: "just me", AuthorID: 2, Visibility: VisibilityAuthorOnly})
+		if err != nil {
+			t.Fatalf("could not create chirp: %v", err)
+		}
+		ids, _ := timelineIDs(10, nil)
+		if len(ids) != 2 {
+			t.Fatalf("Expected the chirp to be left out of the follower's timeline\ngot %v", ids)
+		}
+
+		chirp.Visibility = VisibilityPublic
+		_, err = db.UpdateChirp(chirp)
+		if err != nil {
+			t.Fatalf("could not update chirp: %v", err)
+		}
+		ids, _ = timelineIDs(10, nil)
+		if len(ids) != 3 || ids[0] != chirp.ID {
+			t.Errorf("Expected [%d 2 1]\ngot %v", chirp.ID, ids)
+		}
+
+		err = db.DeleteChirpByID(chirp.ID)
+		if err != nil {
+			t.Fatalf("could not delete chirp: %v", err)
+		}
+	})
+
+	t.Run("Backfill on follow", func(t *testing.T) {
+		_, err := db.CreateFollow(1, 3)
+		if err != nil {
+			t.Fatalf("could not follow user: %v", err)
+		}
+		ids, _ := timelineIDs(10, nil)
+		if len(ids) != 3 || ids[0] != 3 {
+			t.Errorf("Expected [3 2 1]\ngot %v", ids)
+		}
+	})
+}
+
+func TestTimelineWithoutStoredTimeline(t *testing.T) {
+	db := newTestDB(t)
+
+	// A user from before timelines were introduced
+	err := db.writeDB(DBStructure{
+		Users: map[int]User{1: {ID: 1}, 2: {ID: 2}},
+		Chirps: map[int]Chirp{
+			1: {ID: 1, Body: "mine", AuthorID: 1},
+			2: {ID: 2, Body: "theirs", AuthorID: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not write database: %v", err)
+	}
+
+	all := func(Chirp) bool { return true }
+	storedTimeline := func(userID int) bool {
+		dbStruct, err := db.loadDB()
+		if err != nil {
+			t.Fatalf("could not load database: %v", err)
+		}
+		_, ok := dbStruct.Timelines[userID]
+		return ok
+	}
+
+	t.Run("Test reading stores the timeline", func(t *testing.T) {
+		chirps, _, err := db.GetTimeline(1, nil, 10, all)
+		if err != nil {
+			t.Fatalf("could not get timeline: %v", err)
+		}
+		if len(chirps) != 1 || chirps[0].ID != 1 {
+			t.Errorf("Expected the user's own chirp\ngot %v", chirps)
+		}
+		if !storedTimeline(1) {
+			t.Errorf("Expected the timeline to be stored")
+		}
+	})
+
+	t.Run("Test following stores the timeline", func(t *testing.T) {
+		_, err := db.CreateFollow(2, 1)
+		if err != nil {
+			t.Fatalf("could not follow user: %v", err)
+		}
+		if !storedTimeline(2) {
+			t.Errorf("Expected the timeline to be stored")
+		}
+		chirps, _, err := db.GetTimeline(2, nil, 10, all)
+		if err != nil || len(chirps) != 2 {
+			t.Errorf("Expected 2 chirps\ngot %d, '%v'", len(chirps), err)
+		}
+	})
+
+	t.Run("Test unknown user", func(t *testing.T) {
+		_, _, err := db.GetTimeline(3, nil, 10, all)
+		if !errors.Is(err, ErrUserNotExist) {
+			t.Errorf("Expected error '%v'\ngot '%v'", ErrUserNotExist, err)
+		}
+	})
+}
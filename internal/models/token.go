@@ -13,15 +13,24 @@ import (
 const (
 	RefreshTokenLen   = 32
 	TokenExpiryInDays = 60
+
+	// MaxSessionsPerUser limits how many devices a user can be logged in on at once
+	MaxSessionsPerUser = 50
 )
 
 var ErrTokenNotExist = errors.New("Token does not exist")
 
+// Token is a refresh token. Every login gets its own token, so each one is a session
+// on one of the user's devices.
 type Token struct {
-	ID        int       `json:"id"`
-	Plaintext string    `json:"plaintext"`
-	Expiry    time.Time `json:"expiry"`
-	UserID    int       `json:"user_id"`
+	ID         int       `json:"id"`
+	Plaintext  string    `json:"plaintext"`
+	Expiry     time.Time `json:"expiry"`
+	UserID     int       `json:"user_id"`
+	DeviceName string    `json:"device_name,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
 }
 
 func (db *DB) generateRefreshToken(n int) (string, error) {
@@ -35,25 +44,86 @@ func (db *DB) generateRefreshToken(n int) (string, error) {
 	return hex.EncodeToString(byteArr), nil
 }
 
-func (db *DB) tokenByUserID(refreshTokens []Token, userID int) (Token, error) {
-	for _, token := range refreshTokens {
-		if token.UserID == userID {
-			return token, nil
-		}
+func (db *DB) GetRefreshTokenByteLen(tokenPlaintext string) (int, error) {
+	hexBytes, err := hex.DecodeString(tokenPlaintext)
+	if err != nil {
+		return 0, err
 	}
-	return Token{}, ErrTokenNotExist
+	return len(hexBytes), nil
 }
 
-func (db *DB) createNewToken(tokenPlaintext string, userID, lastID int) Token {
-	return Token{
-		ID:        lastID,
-		Plaintext: tokenPlaintext,
-		Expiry:    time.Now().Add(TokenExpiryInDays * 24 * time.Hour),
-		UserID:    userID,
+// CreateRefreshToken starts a new session for the user. Other sessions are kept, apart
+// from expired ones and the least recently used ones once the user has more than
+// MaxSessionsPerUser.
+func (db *DB) CreateRefreshToken(userID int, deviceName, ip string) (Token, error) {
+	// Lock db and defer unlocking
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Load db
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Token{}, err
+	}
+
+	// Generate refresh token
+	tokenPlaintext, err := db.generateRefreshToken(RefreshTokenLen)
+	if err != nil {
+		return Token{}, err
+	}
+
+	// Session IDs aren't reused so that access tokens for an ended session can't match
+	// a new one
+	id := max(nextID(dbStruct.Tokens), dbStruct.LastTokenID+1)
+	dbStruct.LastTokenID = id
+
+	now := time.Now().UTC()
+	token := Token{
+		ID:         id,
+		Plaintext:  tokenPlaintext,
+		Expiry:     now.Add(TokenExpiryInDays * 24 * time.Hour),
+		UserID:     userID,
+		DeviceName: deviceName,
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+
+	// Clear out the user's expired sessions and make room for the new one
+	var sessions []Token
+	for id, t := range dbStruct.Tokens {
+		if t.UserID != userID {
+			continue
+		}
+		if now.After(t.Expiry) {
+			delete(dbStruct.Tokens, id)
+			continue
+		}
+		sessions = append(sessions, t)
+	}
+	slices.SortFunc(sessions, func(a, b Token) int {
+		return a.LastUsedAt.Compare(b.LastUsedAt)
+	})
+	for len(sessions) >= MaxSessionsPerUser {
+		delete(dbStruct.Tokens, sessions[0].ID)
+		sessions = sessions[1:]
+	}
+
+	// Write token to disk
+	if dbStruct.Tokens == nil {
+		dbStruct.Tokens = make(map[int]Token)
 	}
+	dbStruct.Tokens[token.ID] = token
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return token, nil
 }
 
-func (db *DB) GetTokenByUserID(userID int) (Token, error) {
+// GetRefreshToken returns the token with the given plaintext
+func (db *DB) GetRefreshToken(tokenPlaintext string) (Token, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
@@ -63,7 +133,7 @@ func (db *DB) GetTokenByUserID(userID int) (Token, error) {
 	}
 
 	for _, token := range dbStruct.Tokens {
-		if token.UserID == userID {
+		if token.Plaintext == tokenPlaintext {
 			return token, nil
 		}
 	}
@@ -71,73 +141,110 @@ func (db *DB) GetTokenByUserID(userID int) (Token, error) {
 	return Token{}, ErrTokenNotExist
 }
 
-func (db *DB) GetRefreshTokenByteLen(tokenPlaintext string) (int, error) {
-	hexBytes, err := hex.DecodeString(tokenPlaintext)
+// TouchRefreshToken records that a session was used from ip
+func (db *DB) TouchRefreshToken(tokenID int, ip string, now time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return len(hexBytes), nil
+
+	token, ok := dbStruct.Tokens[tokenID]
+	if !ok {
+		return ErrTokenNotExist
+	}
+	token.LastUsedAt = now
+	token.IP = ip
+	dbStruct.Tokens[tokenID] = token
+
+	return db.writeDB(dbStruct)
 }
 
-func (db *DB) CreateRefreshToken(userID int) (Token, error) {
-	// Lock db and defer unlocking
-	db.mu.Lock()
-	defer db.mu.Unlock()
+// GetUserSessions returns the user's unexpired sessions, most recently used first
+func (db *DB) GetUserSessions(userID int) ([]Token, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 
-	// Load db
 	dbStruct, err := db.loadDB()
 	if err != nil {
-		return Token{}, err
+		return nil, err
 	}
 
-	// Generate refresh token
-	tokenPlaintext, err := db.generateRefreshToken(RefreshTokenLen)
+	now := time.Now()
+	sessions := []Token{}
+	for _, token := range dbStruct.Tokens {
+		if token.UserID == userID && !now.After(token.Expiry) {
+			sessions = append(sessions, token)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b Token) int {
+		if c := -a.LastUsedAt.Compare(b.LastUsedAt); c != 0 {
+			return c
+		}
+		return -cmp.Compare(a.ID, b.ID)
+	})
+
+	return sessions, nil
+}
+
+// GetUserSession returns one of the user's unexpired sessions. Sessions belonging to
+// other users are reported as not existing.
+func (db *DB) GetUserSession(userID, tokenID int) (Token, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dbStruct, err := db.loadDB()
 	if err != nil {
 		return Token{}, err
 	}
 
-	// Get tokens if they exist, sort them, and identify the latest ID
-	lastID := 0
-	var tokens []Token
-	if len(dbStruct.Tokens) > 0 {
-		for _, token := range dbStruct.Tokens {
-			tokens = append(tokens, token)
-		}
-		slices.SortFunc(tokens, func(a, b Token) int {
-			return -cmp.Compare(a.ID, b.ID)
-		})
-		lastID = tokens[0].ID
-	}
-
-	// Check if token exists for current user in the event that the user is logging in again
-	// and then overwrite it.
-	// Find a way to rewrite this in a way that eliminates the redundancy later.
-	var token Token
-	if len(tokens) > 0 {
-		token, err = db.tokenByUserID(tokens, userID)
-		if errors.Is(err, ErrTokenNotExist) {
-			lastID++
-			token = db.createNewToken(tokenPlaintext, userID, lastID)
-		} else {
-			lastID = token.ID
-			token.Plaintext = tokenPlaintext
-		}
-	} else {
-		lastID++
-		token = db.createNewToken(tokenPlaintext, userID, lastID)
+	token, ok := dbStruct.Tokens[tokenID]
+	if !ok || token.UserID != userID || time.Now().After(token.Expiry) {
+		return Token{}, ErrTokenNotExist
 	}
 
-	// Write token to disk
-	if len(dbStruct.Tokens) == 0 {
-		dbStruct.Tokens = make(map[int]Token)
+	return token, nil
+}
+
+// DeleteUserSession logs the user out of one session. Sessions belonging to other users
+// are reported as not existing.
+func (db *DB) DeleteUserSession(userID, tokenID int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
 	}
-	dbStruct.Tokens[lastID] = token
-	err = db.writeDB(dbStruct)
+
+	token, ok := dbStruct.Tokens[tokenID]
+	if !ok || token.UserID != userID {
+		return ErrTokenNotExist
+	}
+	delete(dbStruct.Tokens, tokenID)
+
+	return db.writeDB(dbStruct)
+}
+
+// DeleteUserSessions logs the user out everywhere
+func (db *DB) DeleteUserSessions(userID int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
 	if err != nil {
-		return Token{}, err
+		return err
 	}
 
-	return token, nil
+	for id, token := range dbStruct.Tokens {
+		if token.UserID == userID {
+			delete(dbStruct.Tokens, id)
+		}
+	}
+
+	return db.writeDB(dbStruct)
 }
 
 // For revoking refresh tokens
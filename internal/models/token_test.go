@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	db := newTestDB(t)
+
+	laptop, err := db.CreateRefreshToken(1, "Laptop", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("could not create refresh token: %v", err)
+	}
+	phone, err := db.CreateRefreshToken(1, "Phone", "10.0.0.2")
+	if err != nil {
+		t.Fatalf("could not create refresh token: %v", err)
+	}
+	other, err := db.CreateRefreshToken(2, "Other", "10.0.0.3")
+	if err != nil {
+		t.Fatalf("could not create refresh token: %v", err)
+	}
+
+	// Logging in on the phone mustn't log the laptop out
+	err = db.TouchRefreshToken(laptop.ID, "10.0.0.4", time.Now().UTC().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("could not update session: %v", err)
+	}
+	sessions, err := db.GetUserSessions(1)
+	if err != nil {
+		t.Fatalf("could not get sessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != laptop.ID || sessions[0].IP != "10.0.0.4" {
+		t.Fatalf("Expected the laptop session to be listed first with its new IP\ngot %+v", sessions)
+	}
+
+	err = db.DeleteUserSession(1, other.ID)
+	if !errors.Is(err, ErrTokenNotExist) {
+		t.Errorf("Expected error '%v'\ngot '%v'", ErrTokenNotExist, err)
+	}
+
+	err = db.DeleteUserSession(1, phone.ID)
+	if err != nil {
+		t.Fatalf("could not delete session: %v", err)
+	}
+	_, err = db.GetRefreshToken(phone.Plaintext)
+	if !errors.Is(err, ErrTokenNotExist) {
+		t.Errorf("Expected the phone's refresh token to be revoked\ngot '%v'", err)
+	}
+
+	err = db.DeleteUserSessions(1)
+	if err != nil {
+		t.Fatalf("could not delete sessions: %v", err)
+	}
+	sessions, err = db.GetUserSessions(1)
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("Expected every session to be deleted\ngot %d, '%v'", len(sessions), err)
+	}
+	_, err = db.GetUserSession(2, other.ID)
+	if err != nil {
+		t.Errorf("Expected other users' sessions to be kept\ngot '%v'", err)
+	}
+
+	// Session IDs of ended sessions mustn't be handed out again
+	err = db.DeleteUserSession(2, other.ID)
+	if err != nil {
+		t.Fatalf("could not delete session: %v", err)
+	}
+	next, err := db.CreateRefreshToken(1, "Laptop", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("could not create refresh token: %v", err)
+	}
+	if next.ID <= other.ID {
+		t.Errorf("Expected a session ID greater than %d\ngot %d", other.ID, next.ID)
+	}
+}
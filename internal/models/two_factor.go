@@ -0,0 +1,203 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/totp"
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets when they turn on two-factor
+// authentication. Each one can be used once instead of a TOTP code.
+const RecoveryCodeCount = 10
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("Two-factor authentication is already enabled")
+	ErrTOTPNotEnabled     = errors.New("Two-factor authentication is not enabled")
+	ErrTOTPNotEnrolling   = errors.New("Start two-factor enrolment before confirming it")
+	ErrInvalidTOTPCode    = errors.New("Authentication code is incorrect")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// BeginTOTPEnrolment stores a new secret for the user. Two-factor authentication isn't
+// turned on until ConfirmTOTP is called with a code made from the secret, so starting
+// again replaces the secret.
+func (db *DB) BeginTOTPEnrolment(userID int, secret string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	user, ok := dbStruct.Users[userID]
+	if !ok {
+		return ErrUserNotExist
+	}
+	if user.TOTPEnabled {
+		return ErrTOTPAlreadyEnabled
+	}
+
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	dbStruct.Users[userID] = user
+
+	return db.writeDB(dbStruct)
+}
+
+// ConfirmTOTP turns on two-factor authentication if code was made from the secret
+// stored by BeginTOTPEnrolment. It returns the plaintext recovery codes, only their
+// hashes are stored.
+func (db *DB) ConfirmTOTP(userID int, code string, now time.Time) ([]string, error) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	user, ok := dbStruct.Users[userID]
+	if !ok {
+		return nil, ErrUserNotExist
+	}
+	switch {
+	case user.TOTPEnabled:
+		return nil, ErrTOTPAlreadyEnabled
+	case user.TOTPSecret == "":
+		return nil, ErrTOTPNotEnrolling
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, now, user.TOTPLastStep)
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.RecoveryCodes = hashes
+	dbStruct.Users[userID] = user
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// VerifySecondFactor checks a TOTP code or a recovery code for the user. TOTP codes
+// can't be used twice and recovery codes are used up. It returns the number of
+// recovery codes the user has left.
+func (db *DB) VerifySecondFactor(userID int, code string, now time.Time) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return 0, err
+	}
+
+	user, ok := dbStruct.Users[userID]
+	if !ok {
+		return 0, ErrUserNotExist
+	}
+	if !user.TOTPEnabled {
+		return 0, ErrTOTPNotEnabled
+	}
+
+	if step, ok := totp.Validate(user.TOTPSecret, code, now, user.TOTPLastStep); ok {
+		user.TOTPLastStep = step
+	} else {
+		i := findRecoveryCode(user.RecoveryCodes, code)
+		if i < 0 {
+			return len(user.RecoveryCodes), ErrInvalidTOTPCode
+		}
+		user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+	}
+	dbStruct.Users[userID] = user
+
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(user.RecoveryCodes), nil
+}
+
+// DisableTOTP turns off two-factor authentication and removes the secret and recovery
+// codes
+func (db *DB) DisableTOTP(userID int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	user, ok := dbStruct.Users[userID]
+	if !ok {
+		return ErrUserNotExist
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	dbStruct.Users[userID] = user
+
+	return db.writeDB(dbStruct)
+}
+
+// generateRecoveryCodes returns new recovery codes such as "abcde-fghij" along with
+// their hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		// 50 bits each, which is plenty for a code that can only be tried a few times
+		// before logins are throttled
+		byteArr := make([]byte, 7)
+		_, err := rand.Read(byteArr)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(byteArr))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case and dashes so that codes can be typed in loosely
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return hashResetToken("recovery:" + code)
+}
+
+func findRecoveryCode(hashes []string, code string) int {
+	hash := hashRecoveryCode(code)
+	found := -1
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			found = i
+		}
+	}
+	return found
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/totp"
+)
+
+func TestTwoFactor(t *testing.T) {
+	db := newTestDB(t)
+
+	user, err := db.CreateUser("a@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+
+	_, err = db.ConfirmTOTP(user.ID, "123456", time.Now())
+	if !errors.Is(err, ErrTOTPNotEnrolling) {
+		t.Errorf("Expected error '%v'\ngot '%v'", ErrTOTPNotEnrolling, err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("could not generate secret: %v", err)
+	}
+	err = db.BeginTOTPEnrolment(user.ID, secret)
+	if err != nil {
+		t.Fatalf("could not start enrolment: %v", err)
+	}
+
+	now := time.Now()
+	code, _ := totp.Code(secret, totp.Step(now))
+	recoveryCodes, err := db.ConfirmTOTP(user.ID, code, now)
+	if err != nil {
+		t.Fatalf("could not confirm TOTP: %v", err)
+	}
+	if len(recoveryCodes) != RecoveryCodeCount {
+		t.Errorf("Expected %d recovery codes\ngot %d", RecoveryCodeCount, len(recoveryCodes))
+	}
+
+	// Only hashes of the recovery codes are stored
+	stored, err := db.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("could not get user: %v", err)
+	}
+	for _, hash := range stored.RecoveryCodes {
+		if hash == recoveryCodes[0] {
+			t.Errorf("Expected recovery codes to be hashed")
+		}
+	}
+
+	// The code used to confirm can't be used again
+	_, err = db.VerifySecondFactor(user.ID, code, now)
+	if !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("Expected error '%v'\ngot '%v'", ErrInvalidTOTPCode, err)
+	}
+
+	next, _ := totp.Code(secret, totp.Step(now)+1)
+	_, err = db.VerifySecondFactor(user.ID, next, now)
+	if err != nil {
+		t.Errorf("Expected the next code to work\ngot '%v'", err)
+	}
+
+	// Recovery codes work once and ignore case
+	remaining, err := db.VerifySecondFactor(user.ID, strings.ToUpper(recoveryCodes[3]), now)
+	if err != nil || remaining != RecoveryCodeCount-1 {
+		t.Errorf("Expected recovery code to work leaving %d\ngot %d, '%v'", RecoveryCodeCount-1, remaining, err)
+	}
+	_, err = db.VerifySecondFactor(user.ID, recoveryCodes[3], now)
+	if !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("Expected a used recovery code to be rejected\ngot '%v'", err)
+	}
+
+	err = db.DisableTOTP(user.ID)
+	if err != nil {
+		t.Fatalf("could not disable TOTP: %v", err)
+	}
+	_, err = db.VerifySecondFactor(user.ID, recoveryCodes[4], now)
+	if !errors.Is(err, ErrTOTPNotEnabled) {
+		t.Errorf("Expected error '%v'\ngot '%v'", ErrTOTPNotEnabled, err)
+	}
+}
@@ -4,28 +4,114 @@ import (
 	"cmp"
 	"errors"
 	"slices"
+	"strings"
+	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/password"
 )
 
-var ErrUserNotExist = errors.New("User does not exist")
+var (
+	ErrUserNotExist = errors.New("User does not exist")
+	ErrEmailTaken   = errors.New("Account with that email address already exists")
+	ErrEmailChanged = errors.New("Email address has changed")
+	ErrUnverified   = errors.New("Email address has not been verified")
+)
 
 type User struct {
-	ID          int    `json:"id"`
-	Email       string `json:"email"`
-	Password    string `json:"password"`
-	IsChirpyRed bool   `json:"is_chirpy_red"`
+	ID          int        `json:"id"`
+	Email       string     `json:"email"`
+	Password    string     `json:"password"`
+	IsChirpyRed bool       `json:"is_chirpy_red"`
+	IsAdmin     bool       `json:"is_admin"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+	Handle      string     `json:"handle,omitempty"`
+	DisplayName string     `json:"display_name,omitempty"`
+	Bio         string     `json:"bio,omitempty"`
+	Avatar      string     `json:"avatar,omitempty"`
+
+	EmailVerified      bool       `json:"email_verified"`
+	VerificationSentAt *time.Time `json:"verification_sent_at,omitempty"`
+
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+
+	// TOTPSecret is set while enrolling in two-factor authentication and stays set once
+	// TOTPEnabled. RecoveryCodes holds hashes of the unused recovery codes.
+	TOTPSecret    string   `json:"totp_secret,omitempty"`
+	TOTPEnabled   bool     `json:"totp_enabled,omitempty"`
+	TOTPLastStep  int64    `json:"totp_last_step,omitempty"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
-const CryptCost = 12
+func (u User) IsSuspended() bool {
+	return u.SuspendedAt != nil
+}
+
+// IsPendingDeletion reports whether the user has asked for their account to be deleted
+// and hasn't cancelled it yet
+func (u User) IsPendingDeletion() bool {
+	return u.DeletionScheduledAt != nil
+}
+
+// SetPasswordHasher changes how new passwords are hashed. Existing hashes can still be
+// checked and are replaced when RehashPassword is called.
+func (db *DB) SetPasswordHasher(hasher password.Hasher) {
+	db.hasher = hasher
+
+	// Make the hash used for unknown email addresses straight away so that the first
+	// login with one doesn't take longer than the rest
+	db.dummyMu.Lock()
+	db.dummy = ""
+	db.dummyMu.Unlock()
+	db.dummyHash()
+}
 
-// Not sure if I even need this function. Will keep it for now.
 func (db *DB) hashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), CryptCost)
+	return db.hasher.Hash(password)
+}
+
+// CheckPassword returns password.ErrMismatchedPassword if password isn't the user's
+// password
+func (db *DB) CheckPassword(user User, password string) error {
+	return db.hasher.Compare(user.Password, password)
+}
+
+// RehashPassword replaces the user's password hash if it was made with outdated
+// settings. password must already have been checked with CheckPassword. The hash is
+// left alone if the password was changed in the meantime.
+func (db *DB) RehashPassword(user User, password string) (User, error) {
+	if !db.hasher.NeedsRehash(user.Password) {
+		return user, nil
+	}
+
+	hashedPass, err := db.hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return User{}, err
+	}
+
+	current, ok := dbStruct.Users[user.ID]
+	if !ok {
+		return User{}, ErrUserNotExist
+	}
+	if current.Password != user.Password {
+		return current, nil
+	}
+
+	current.Password = hashedPass
+	dbStruct.Users[user.ID] = current
+	err = db.writeDB(dbStruct)
 	if err != nil {
-		return "", err
+		return User{}, err
 	}
-	return string(hash), nil
+
+	return current, nil
 }
 
 // Think of a better way of doing this later
@@ -52,13 +138,23 @@ func (db *DB) GetUserByEmail(email string) (User, error) {
 	}
 
 	// Check if user with specified email exists
+	user, ok := findUserByEmail(dbStruct, email)
+	if !ok {
+		return User{}, ErrUserNotExist
+	}
+
+	return user, nil
+}
+
+// findUserByEmail looks up a user by email address ignoring case, since nearly every
+// mail server treats addresses that way
+func findUserByEmail(dbStruct DBStructure, email string) (User, bool) {
 	for _, user := range dbStruct.Users {
-		if user.Email == email {
-			return user, nil
+		if strings.EqualFold(user.Email, email) {
+			return user, true
 		}
 	}
-
-	return User{}, ErrUserNotExist
+	return User{}, false
 }
 
 func (db *DB) GetUserByID(id int) (User, error) {
@@ -80,7 +176,8 @@ func (db *DB) GetUserByID(id int) (User, error) {
 	return User{}, ErrUserNotExist
 }
 
-func (db *DB) CreateUser(email, password string) (User, error) {
+// CreateUser saves a new user. handle is optional and must already be normalized.
+func (db *DB) CreateUser(email, password, handle string) (User, error) {
 	// Lock db and defer unlocking
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -91,6 +188,13 @@ func (db *DB) CreateUser(email, password string) (User, error) {
 		return User{}, err
 	}
 
+	if _, ok := findUserByEmail(dbStruct, email); ok {
+		return User{}, ErrEmailTaken
+	}
+	if handle != "" && !handleAvailable(dbStruct, handle, 0, time.Now()) {
+		return User{}, ErrHandleTaken
+	}
+
 	// Get the last ID (i.e., the largest ID)
 	var users []User
 	lastID := 0
@@ -107,6 +211,9 @@ func (db *DB) CreateUser(email, password string) (User, error) {
 		lastID = users[0].ID
 	}
 
+	// IDs of deleted users aren't reused
+	lastID = max(lastID, dbStruct.LastUserID)
+
 	// Create user
 	hashedPass, err := db.hashPassword(password)
 	if err != nil {
@@ -119,6 +226,7 @@ func (db *DB) CreateUser(email, password string) (User, error) {
 		Email:       email,
 		Password:    hashedPass,
 		IsChirpyRed: false,
+		Handle:      handle,
 	}
 
 	// Write user to disk
@@ -126,6 +234,8 @@ func (db *DB) CreateUser(email, password string) (User, error) {
 		dbStruct.Users = make(map[int]User)
 	}
 	dbStruct.Users[lastID] = user
+	dbStruct.LastUserID = lastID
+	ensureTimeline(&dbStruct, lastID)
 	err = db.writeDB(dbStruct)
 	if err != nil {
 		return User{}, err
@@ -134,7 +244,30 @@ func (db *DB) CreateUser(email, password string) (User, error) {
 	return user, nil
 }
 
-func (db *DB) UpdateUser(id int, email, password string) error {
+// UserUpdate holds the changes to make to a user. Fields that are nil are left alone.
+// Values must already be validated and normalized.
+type UserUpdate struct {
+	Email       *string
+	Password    *string
+	Handle      *string
+	DisplayName *string
+	Bio         *string
+	Avatar      *string
+}
+
+// UpdateUser applies update to the user with the given ID. Either every change is saved
+// or none of them are.
+func (db *DB) UpdateUser(id int, update UserUpdate) (User, error) {
+	// Hash the new password before taking the lock since it's slow
+	var hashedPass string
+	if update.Password != nil {
+		var err error
+		hashedPass, err = db.hashPassword(*update.Password)
+		if err != nil {
+			return User{}, err
+		}
+	}
+
 	// Lock db and defer unlocking
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -142,31 +275,139 @@ func (db *DB) UpdateUser(id int, email, password string) error {
 	// Load db
 	dbStruct, err := db.loadDB()
 	if err != nil {
-		return err
+		return User{}, err
 	}
 
 	// Check that user with matching ID actually exists
 	user, ok := dbStruct.Users[id]
 	if !ok {
-		return ErrUserNotExist
+		return User{}, ErrUserNotExist
+	}
+
+	if update.Email != nil {
+		if other, ok := findUserByEmail(dbStruct, *update.Email); ok && other.ID != id {
+			return User{}, ErrEmailTaken
+		}
+		// The new address hasn't been proven to belong to the user yet
+		if !strings.EqualFold(user.Email, *update.Email) {
+			user.EmailVerified = false
+			user.VerificationSentAt = nil
+		}
+		user.Email = *update.Email
+	}
+	if update.Password != nil {
+		user.Password = hashedPass
+	}
+	if update.Handle != nil {
+		err = setHandle(&dbStruct, &user, *update.Handle, time.Now())
+		if err != nil {
+			return User{}, err
+		}
+	}
+	if update.DisplayName != nil {
+		user.DisplayName = *update.DisplayName
+	}
+	if update.Bio != nil {
+		user.Bio = *update.Bio
+	}
+	if update.Avatar != nil {
+		user.Avatar = *update.Avatar
 	}
 
 	// Write updated user info to disk
-	hashedPass, err := db.hashPassword(password)
+	dbStruct.Users[id] = user
+	err = db.writeDB(dbStruct)
 	if err != nil {
-		return err
+		return User{}, err
 	}
-	user = User{
-		ID:          id,
-		Email:       email,
-		Password:    hashedPass,
-		IsChirpyRed: user.IsChirpyRed,
+
+	return user, nil
+}
+
+// MarkEmailVerified marks the user's email address as verified. email is the address
+// the verification was sent to, and ErrEmailChanged is returned if the user has since
+// switched to a different one.
+func (db *DB) MarkEmailVerified(id int, email string) (User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return User{}, err
+	}
+
+	user, ok := dbStruct.Users[id]
+	if !ok {
+		return User{}, ErrUserNotExist
+	}
+	if !strings.EqualFold(user.Email, email) {
+		return User{}, ErrEmailChanged
+	}
+	if user.EmailVerified {
+		return user, nil
 	}
+
+	user.EmailVerified = true
 	dbStruct.Users[id] = user
 	err = db.writeDB(dbStruct)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// SetAdmin makes the user with the given email address an admin. Only verified
+// addresses are accepted so that an admin address can't be claimed by someone signing
+// up with it first.
+func (db *DB) SetAdmin(email string) (User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return User{}, err
+	}
+
+	user, ok := findUserByEmail(dbStruct, email)
+	if !ok {
+		return User{}, ErrUserNotExist
+	}
+	if !user.EmailVerified {
+		return User{}, ErrUnverified
+	}
+	if user.IsAdmin {
+		return user, nil
+	}
+
+	user.IsAdmin = true
+	dbStruct.Users[user.ID] = user
+	err = db.writeDB(dbStruct)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// SetVerificationSent records when a verification email was last sent to the user
+func (db *DB) SetVerificationSent(id int, sentAt time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStruct, err := db.loadDB()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	user, ok := dbStruct.Users[id]
+	if !ok {
+		return ErrUserNotExist
+	}
+
+	sentAt = sentAt.UTC()
+	user.VerificationSentAt = &sentAt
+	dbStruct.Users[id] = user
+
+	return db.writeDB(dbStruct)
 }
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/password"
+)
+
+func TestRehashPassword(t *testing.T) {
+	db := newTestDB(t)
+
+	db.SetPasswordHasher(password.Hasher{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4})
+	user, err := db.CreateUser("a@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+
+	db.SetPasswordHasher(password.Hasher{
+		Algorithm: password.AlgorithmArgon2id,
+		Argon2:    password.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	})
+
+	// Old hashes can still be checked after the settings change
+	err = db.CheckPassword(user, "password")
+	if err != nil {
+		t.Fatalf("Expected old hash to match\ngot '%v'", err)
+	}
+
+	rehashed, err := db.RehashPassword(user, "password")
+	if err != nil {
+		t.Fatalf("could not rehash password: %v", err)
+	}
+	if !strings.HasPrefix(rehashed.Password, "$argon2id$") {
+		t.Errorf("Expected an argon2id hash\ngot '%v'", rehashed.Password)
+	}
+
+	stored, err := db.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("could not get user: %v", err)
+	}
+	if stored.Password != rehashed.Password {
+		t.Errorf("Expected the new hash to be saved")
+	}
+	err = db.CheckPassword(stored, "password")
+	if err != nil {
+		t.Errorf("Expected new hash to match\ngot '%v'", err)
+	}
+
+	// A stale copy of the user mustn't overwrite a password that has since changed
+	again, err := db.RehashPassword(user, "password")
+	if err != nil {
+		t.Fatalf("could not rehash password: %v", err)
+	}
+	if again.Password != stored.Password {
+		t.Errorf("Expected the saved hash to be kept")
+	}
+}
+
+func TestSetAdmin(t *testing.T) {
+	db := newTestDB(t)
+
+	verified, err := db.CreateUser("admin@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	_, err = db.MarkEmailVerified(verified.ID, verified.Email)
+	if err != nil {
+		t.Fatalf("could not verify email: %v", err)
+	}
+	_, err = db.CreateUser("unverified@example.com", "password", "")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		email     string
+		wantErr   error
+		wantAdmin bool
+	}{
+		{"Test verified user", "Admin@Example.com", nil, true},
+		{"Test unverified user", "unverified@example.com", ErrUnverified, false},
+		{"Test unknown user", "nobody@example.com", ErrUserNotExist, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := db.SetAdmin(c.email)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+			user, err := db.GetUserByEmail(c.email)
+			if err == nil && user.IsAdmin != c.wantAdmin {
+				t.Errorf("Expected admin to be %v\ngot %v", c.wantAdmin, user.IsAdmin)
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	db := newTestDB(t)
+
+	user, err := db.CreateUser("a@example.com", "password", "alice")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	_, err = db.CreateUser("b@example.com", "password", "bob")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	_, err = db.MarkEmailVerified(user.ID, user.Email)
+	if err != nil {
+		t.Fatalf("could not verify email: %v", err)
+	}
+
+	ptr := func(s string) *string { return &s }
+	cases := []struct {
+		name    string
+		update  UserUpdate
+		wantErr error
+		check   func(before, after User) bool
+	}{
+		{
+			"Test partial update only changes given fields",
+			UserUpdate{Bio: ptr("Hello")},
+			nil,
+			func(before, after User) bool {
+				return after.Bio == "Hello" && after.DisplayName == before.DisplayName &&
+					after.Email == before.Email && after.Handle == before.Handle && after.Password == before.Password
+			},
+		},
+		{
+			"Test email taken regardless of case",
+			UserUpdate{Email: ptr("B@Example.com")},
+			ErrEmailTaken,
+			func(before, after User) bool { return after.Email == before.Email },
+		},
+		{
+			"Test nothing saved when handle is taken",
+			UserUpdate{Email: ptr("new@example.com"), DisplayName: ptr("Alice"), Handle: ptr("bob")},
+			ErrHandleTaken,
+			func(before, after User) bool {
+				return after.Email == before.Email && after.DisplayName == before.DisplayName &&
+					after.Handle == before.Handle && after.EmailVerified
+			},
+		},
+		{
+			"Test changing case of own email keeps it verified",
+			UserUpdate{Email: ptr("A@example.com")},
+			nil,
+			func(before, after User) bool { return after.Email == "A@example.com" && after.EmailVerified },
+		},
+		{
+			"Test new email has to be verified again",
+			UserUpdate{Email: ptr("new@example.com")},
+			nil,
+			func(before, after User) bool { return after.Email == "new@example.com" && !after.EmailVerified },
+		},
+		{
+			"Test new password is hashed",
+			UserUpdate{Password: ptr("new password")},
+			nil,
+			func(before, after User) bool {
+				return after.Password != before.Password && db.CheckPassword(after, "new password") == nil
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			before, err := db.GetUserByID(user.ID)
+			if err != nil {
+				t.Fatalf("could not get user: %v", err)
+			}
+			_, err = db.UpdateUser(user.ID, c.update)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+			after, err := db.GetUserByID(user.ID)
+			if err != nil {
+				t.Fatalf("could not get user: %v", err)
+			}
+			if !c.check(before, after) {
+				t.Errorf("Unexpected user after update\nbefore '%+v'\nafter '%+v'", before, after)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/filter"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+// DefaultPipelineSpec only masks profanity, which is how chirps were always handled
+const DefaultPipelineSpec = "mask"
+
+// Stage is a single step of the moderation pipeline. A stage may rewrite the body of
+// the chirp and returns the decision it made so that it can be recorded on the chirp.
+type Stage interface {
+	Moderate(body string) (string, models.ModerationDecision)
+}
+
+// Result is the outcome of running a chirp through the pipeline
+type Result struct {
+	Body      string
+	Status    models.ChirpStatus
+	Flagged   bool
+	Rejected  bool
+	Decisions []models.ModerationDecision
+}
+
+// RejectReason returns the reason given by the stage that rejected the chirp
+func (res Result) RejectReason() string {
+	for _, d := range res.Decisions {
+		if d.Action == models.ModerationReject {
+			return d.Reason
+		}
+	}
+	return ""
+}
+
+// Pipeline passes chirps through its stages in order. Processing stops at the first
+// stage that rejects the chirp.
+type Pipeline struct {
+	stages  []Stage
+	filters []*filter.Filter
+}
+
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Run moderates body and returns the (possibly rewritten) body along with every
+// decision that was made
+func (p *Pipeline) Run(body string) Result {
+	res := Result{
+		Body:   body,
+		Status: models.ChirpPublished,
+	}
+
+	for _, stage := range p.stages {
+		var decision models.ModerationDecision
+		res.Body, decision = stage.Moderate(res.Body)
+		decision.DecidedAt = time.Now().UTC()
+		res.Decisions = append(res.Decisions, decision)
+
+		switch decision.Action {
+		case models.ModerationReject:
+			res.Rejected = true
+			return res
+		case models.ModerationHold:
+			res.Status = models.ChirpHeld
+		case models.ModerationFlag:
+			res.Flagged = true
+		}
+	}
+
+	return res
+}
+
+// Watch reloads the word lists used by the pipeline's stages when they change
+func (p *Pipeline) Watch(ctx context.Context, interval time.Duration) {
+	for _, f := range p.filters {
+		go f.Watch(ctx, interval)
+	}
+}
+
+// MaskStage replaces words from its list
+type MaskStage struct {
+	Name   string
+	Filter *filter.Filter
+}
+
+func (s MaskStage) Moderate(body string) (string, models.ModerationDecision) {
+	cleaned := s.Filter.Clean(body)
+	if cleaned == body {
+		return body, models.ModerationDecision{Stage: s.Name, Action: models.ModerationAllow}
+	}
+	return cleaned, models.ModerationDecision{
+		Stage:  s.Name,
+		Action: models.ModerationMask,
+		Reason: "masked listed words",
+	}
+}
+
+// KeywordStage applies Action to any chirp that contains a word from its list. It is
+// used for the reject, hold and flag stages.
+type KeywordStage struct {
+	Name   string
+	Action models.ModerationAction
+	Filter *filter.Filter
+}
+
+func (s KeywordStage) Moderate(body string) (string, models.ModerationDecision) {
+	if !s.Filter.Matches(body) {
+		return body, models.ModerationDecision{Stage: s.Name, Action: models.ModerationAllow}
+	}
+	return body, models.ModerationDecision{
+		Stage:  s.Name,
+		Action: s.Action,
+		Reason: "contains listed words",
+	}
+}
+
+// ParsePipeline builds a pipeline from a comma separated list of stages, each of the
+// form "kind" or "kind:path" where kind is one of mask, reject, hold or flag and path
+// is a word list file. A mask stage without a path uses profanity. For example:
+//
+//	mask,reject:reject.txt,hold:review.txt,flag:flag.txt
+func ParsePipeline(spec string, profanity *filter.Filter) (*Pipeline, error) {
+	p := &Pipeline{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kind, path, _ := strings.Cut(entry, ":")
+
+		var f *filter.Filter
+		if path == "" {
+			if kind != "mask" {
+				return nil, fmt.Errorf("%s stage requires a word list path", kind)
+			}
+			f = profanity
+		} else {
+			var err error
+			f, err = filter.Load(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s stage: %w", kind, err)
+			}
+			p.filters = append(p.filters, f)
+		}
+
+		switch kind {
+		case "mask":
+			p.stages = append(p.stages, MaskStage{Name: entry, Filter: f})
+		case "reject":
+			p.stages = append(p.stages, KeywordStage{Name: entry, Action: models.ModerationReject, Filter: f})
+		case "hold":
+			p.stages = append(p.stages, KeywordStage{Name: entry, Action: models.ModerationHold, Filter: f})
+		case "flag":
+			p.stages = append(p.stages, KeywordStage{Name: entry, Action: models.ModerationFlag, Filter: f})
+		default:
+			return nil, fmt.Errorf("unknown moderation stage '%s'", kind)
+		}
+	}
+
+	return p, nil
+}
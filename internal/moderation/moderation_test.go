@@ -0,0 +1,81 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/filter"
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+func newTestFilter(t *testing.T, list string) *filter.Filter {
+	l, err := filter.Parse(strings.NewReader(list))
+	if err != nil {
+		t.Fatalf("could not parse list: %v", err)
+	}
+	return filter.New(l)
+}
+
+func TestPipelineRun(t *testing.T) {
+	p := NewPipeline(
+		MaskStage{Name: "mask", Filter: newTestFilter(t, "kerfuffle\n")},
+		KeywordStage{Name: "reject", Action: models.ModerationReject, Filter: newTestFilter(t, "spam\n")},
+		KeywordStage{Name: "hold", Action: models.ModerationHold, Filter: newTestFilter(t, "link\n")},
+		KeywordStage{Name: "flag", Action: models.ModerationFlag, Filter: newTestFilter(t, "fight\n")},
+	)
+
+	cases := []struct {
+		name         string
+		body         string
+		wantBody     string
+		wantStatus   models.ChirpStatus
+		wantFlagged  bool
+		wantRejected bool
+		wantStages   int
+	}{
+		{"Test clean chirp", "hello there", "hello there", models.ChirpPublished, false, false, 4},
+		{"Test masked chirp", "what a kerfuffle", "what a ****", models.ChirpPublished, false, false, 4},
+		{"Test rejected chirp", "buy spam now", "buy spam now", models.ChirpPublished, false, true, 2},
+		{"Test held and flagged chirp", "fight me, link below", "fight me, link below", models.ChirpHeld, true, false, 4},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := p.Run(c.body)
+			if res.Body != c.wantBody {
+				t.Errorf("Expected body '%v'\ngot '%v'", c.wantBody, res.Body)
+			}
+			if res.Rejected != c.wantRejected {
+				t.Errorf("Expected rejected %v\ngot %v", c.wantRejected, res.Rejected)
+			}
+			if !c.wantRejected && (res.Status != c.wantStatus || res.Flagged != c.wantFlagged) {
+				t.Errorf("Expected status %v flagged %v\ngot %v %v", c.wantStatus, c.wantFlagged, res.Status, res.Flagged)
+			}
+			if len(res.Decisions) != c.wantStages {
+				t.Errorf("Expected %d decisions\ngot %d", c.wantStages, len(res.Decisions))
+			}
+		})
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+	profanity := filter.New(filter.DefaultList())
+
+	_, err := ParsePipeline("mask,reject", profanity)
+	if err == nil {
+		t.Errorf("Expected error for reject stage without a word list")
+	}
+
+	_, err = ParsePipeline("mask,shout", profanity)
+	if err == nil {
+		t.Errorf("Expected error for unknown stage")
+	}
+
+	p, err := ParsePipeline(DefaultPipelineSpec, profanity)
+	if err != nil {
+		t.Fatalf("could not parse default pipeline: %v", err)
+	}
+	if got := p.Run("fornax").Body; got != filter.DefaultReplacement {
+		t.Errorf("Expected '%v'\ngot '%v'", filter.DefaultReplacement, got)
+	}
+}
@@ -0,0 +1,188 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithm is a password hashing algorithm a Hasher can use for new hashes
+type Algorithm string
+
+const (
+	AlgorithmBcrypt   Algorithm = "bcrypt"
+	AlgorithmArgon2id Algorithm = "argon2id"
+)
+
+const DefaultBcryptCost = 12
+
+var (
+	ErrMismatchedPassword = errors.New("Password is incorrect")
+	ErrUnknownHash        = errors.New("Unknown password hash format")
+)
+
+// Argon2Params are the settings used for argon2id hashes. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the second recommended option in RFC 9106
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hasher hashes and checks passwords. Every hash records the algorithm and settings
+// that made it, so hashes made with older settings can still be checked and NeedsRehash
+// can tell when one should be replaced.
+//
+// bcrypt hashes use the usual "$2a$cost$..." format and argon2id hashes use the PHC
+// string format "$argon2id$v=19$m=65536,t=3,p=2$salt$key".
+type Hasher struct {
+	Algorithm  Algorithm
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+var DefaultHasher = Hasher{
+	Algorithm:  AlgorithmBcrypt,
+	BcryptCost: DefaultBcryptCost,
+	Argon2:     DefaultArgon2Params,
+}
+
+// ParseAlgorithm checks that name is a supported algorithm
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch algorithm := Algorithm(name); algorithm {
+	case AlgorithmBcrypt, AlgorithmArgon2id:
+		return algorithm, nil
+	default:
+		return "", fmt.Errorf("unknown password hashing algorithm '%s'", name)
+	}
+}
+
+// Hash returns a new hash of password using the hasher's algorithm and settings
+func (h Hasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	case AlgorithmArgon2id:
+		salt := make([]byte, h.Argon2.SaltLength)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, h.Argon2.Iterations, h.Argon2.Memory, h.Argon2.Parallelism, h.Argon2.KeyLength)
+		return encodeArgon2(h.Argon2, salt, key), nil
+	default:
+		return "", fmt.Errorf("unknown password hashing algorithm '%s'", h.Algorithm)
+	}
+}
+
+// Compare checks password against hash, which can have been made by any supported
+// algorithm. It returns ErrMismatchedPassword if the password is wrong.
+func (h Hasher) Compare(hash, password string) error {
+	switch {
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatchedPassword
+		}
+		return err
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrMismatchedPassword
+		}
+		return nil
+	default:
+		return ErrUnknownHash
+	}
+}
+
+// NeedsRehash reports whether hash was made with a different algorithm or different
+// settings from the ones the hasher currently uses
+func (h Hasher) NeedsRehash(hash string) bool {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		if !isBcrypt(hash) {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.BcryptCost
+	case AlgorithmArgon2id:
+		params, salt, _, err := decodeArgon2(hash)
+		if err != nil {
+			return true
+		}
+		params.SaltLength = uint32(len(salt))
+		return params != h.Argon2
+	default:
+		return false
+	}
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func encodeArgon2(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	// The hash starts with '$' so the first part is always empty
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != string(AlgorithmArgon2id) {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var params Argon2Params
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,58 @@
+package password
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHasherUpgrades(t *testing.T) {
+	// Cheap settings so the test runs quickly
+	oldBcrypt := Hasher{Algorithm: AlgorithmBcrypt, BcryptCost: 4}
+	newBcrypt := Hasher{Algorithm: AlgorithmBcrypt, BcryptCost: 5}
+	argon := Hasher{
+		Algorithm: AlgorithmArgon2id,
+		Argon2:    Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	}
+
+	cases := []struct {
+		name        string
+		hashedWith  Hasher
+		checkedWith Hasher
+		wantRehash  bool
+	}{
+		{"Test same bcrypt cost", oldBcrypt, oldBcrypt, false},
+		{"Test raised bcrypt cost", oldBcrypt, newBcrypt, true},
+		{"Test bcrypt to argon2id", oldBcrypt, argon, true},
+		{"Test same argon2id params", argon, argon, false},
+		{"Test argon2id to bcrypt", argon, oldBcrypt, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hash, err := c.hashedWith.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Couldn't hash password: %s", err)
+			}
+
+			err = c.checkedWith.Compare(hash, "correct horse")
+			if err != nil {
+				t.Errorf("Expected password to match\ngot '%v'", err)
+			}
+			err = c.checkedWith.Compare(hash, "wrong horse")
+			if !errors.Is(err, ErrMismatchedPassword) {
+				t.Errorf("Expected '%v'\ngot '%v'", ErrMismatchedPassword, err)
+			}
+
+			if got := c.checkedWith.NeedsRehash(hash); got != c.wantRehash {
+				t.Errorf("Expected NeedsRehash to be %v\ngot %v", c.wantRehash, got)
+			}
+		})
+	}
+}
+
+func TestCompareUnknownHash(t *testing.T) {
+	err := DefaultHasher.Compare("plaintext", "plaintext")
+	if !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("Expected '%v'\ngot '%v'", ErrUnknownHash, err)
+	}
+}
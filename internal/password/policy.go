@@ -0,0 +1,219 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxBytes is the longest password bcrypt can hash. Anything after this is silently
+// ignored by bcrypt, so longer passwords are rejected instead.
+const MaxBytes = 72
+
+const DefaultBreachListPath = "breached_passwords.txt"
+
+var (
+	ErrEmpty         = errors.New("Password must be provided")
+	ErrTooManyBytes  = fmt.Errorf("Password must not be more than %d bytes long", MaxBytes)
+	ErrMissingUpper  = errors.New("Password must contain an uppercase letter")
+	ErrMissingLower  = errors.New("Password must contain a lowercase letter")
+	ErrMissingDigit  = errors.New("Password must contain a digit")
+	ErrMissingSymbol = errors.New("Password must contain a symbol")
+	ErrPersonal      = errors.New("Password can't be the same as your email address or handle")
+	ErrBreached      = errors.New("Password has appeared in a data breach, please choose a different one")
+)
+
+// Class is a kind of character that a policy can require
+type Class string
+
+const (
+	ClassUpper  Class = "upper"
+	ClassLower  Class = "lower"
+	ClassDigit  Class = "digit"
+	ClassSymbol Class = "symbol"
+)
+
+var Classes = []Class{ClassUpper, ClassLower, ClassDigit, ClassSymbol}
+
+// Policy is the set of rules new passwords have to follow. Lengths are counted in
+// characters, and MaxBytes always applies on top of MaxLength.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	Require   []Class
+
+	// Breached is checked when it isn't nil
+	Breached *BreachList
+}
+
+// DefaultPolicy follows NIST SP 800-63B: a reasonable minimum length and no
+// composition rules
+var DefaultPolicy = Policy{
+	MinLength: 8,
+	MaxLength: 64,
+}
+
+// ParseClasses parses a comma separated list of character classes such as "upper,digit"
+func ParseClasses(spec string) ([]Class, error) {
+	var classes []Class
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		class := Class(name)
+		valid := false
+		for _, c := range Classes {
+			valid = valid || c == class
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown character class '%s'", name)
+		}
+		classes = append(classes, class)
+	}
+
+	return classes, nil
+}
+
+// Check returns an error describing the first rule the password breaks. personal holds
+// details such as the user's email address and handle that the password mustn't match.
+func (p Policy) Check(password string, personal ...string) error {
+	if password == "" {
+		return ErrEmpty
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("Password must not be more than %d characters long", p.MaxLength)
+	}
+	if len(password) > MaxBytes {
+		return ErrTooManyBytes
+	}
+
+	for _, class := range p.Require {
+		err := checkClass(password, class)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, detail := range personal {
+		if detail != "" && strings.EqualFold(password, detail) {
+			return ErrPersonal
+		}
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		return ErrBreached
+	}
+
+	return nil
+}
+
+func checkClass(password string, class Class) error {
+	var match func(rune) bool
+	var err error
+	switch class {
+	case ClassUpper:
+		match, err = unicode.IsUpper, ErrMissingUpper
+	case ClassLower:
+		match, err = unicode.IsLower, ErrMissingLower
+	case ClassDigit:
+		match, err = unicode.IsDigit, ErrMissingDigit
+	case ClassSymbol:
+		match = func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+		}
+		err = ErrMissingSymbol
+	default:
+		return nil
+	}
+
+	if strings.IndexFunc(password, match) < 0 {
+		return err
+	}
+	return nil
+}
+
+// BreachList is a set of passwords known to have been leaked. Plain entries are
+// matched ignoring case so that trivial variations are caught as well. Entries can
+// also be SHA-1 hashes, which is the format used by the Have I Been Pwned downloads.
+type BreachList struct {
+	plain  map[string]struct{}
+	hashes map[string]struct{}
+}
+
+// LoadBreachList reads a breach list from a file
+func LoadBreachList(path string) (*BreachList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseBreachList(f)
+}
+
+// ParseBreachList reads a breach list. Each non-empty line that doesn't start with '#'
+// is either a password or a 40 character SHA-1 hash, optionally followed by ":count".
+func ParseBreachList(r io.Reader) (*BreachList, error) {
+	list := &BreachList{
+		plain:  make(map[string]struct{}),
+		hashes: make(map[string]struct{}),
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		if isSHA1(hash) {
+			list.hashes[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+		list.plain[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Contains reports whether the password is on the list
+func (l *BreachList) Contains(password string) bool {
+	if _, ok := l.plain[strings.ToLower(password)]; ok {
+		return true
+	}
+
+	sum := sha1.Sum([]byte(password))
+	_, ok := l.hashes[strings.ToUpper(hex.EncodeToString(sum[:]))]
+	return ok
+}
+
+// Len returns the number of entries on the list
+func (l *BreachList) Len() int {
+	return len(l.plain) + len(l.hashes)
+}
+
+func isSHA1(s string) bool {
+	if len(s) != sha1.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,67 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	breached, err := ParseBreachList(strings.NewReader(
+		"# comment\npassword123\n" +
+			// SHA-1 of "correct horse"
+			"2f9e53523b62abc141a2b4d6019d23cba835dbd0:42\n",
+	))
+	if err != nil {
+		t.Fatalf("Couldn't parse breach list: %s", err)
+	}
+
+	policy := Policy{
+		MinLength: 8,
+		MaxLength: 64,
+		Require:   []Class{ClassDigit},
+		Breached:  breached,
+	}
+
+	cases := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{"Test valid", "s3cure enough", nil},
+		{"Test empty", "", ErrEmpty},
+		{"Test missing digit", "long enough", ErrMissingDigit},
+		{"Test over 72 bytes", strings.Repeat("é1", 30), ErrTooManyBytes},
+		{"Test breached ignoring case", "PASSWORD123", ErrBreached},
+		{"Test same as email", "User1@example.com", ErrPersonal},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := policy.Check(c.password, "user1@example.com", "user1")
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+		})
+	}
+
+	// Length errors include the limit so they're checked by message
+	err = policy.Check("sh0rt")
+	if err == nil || !strings.Contains(err.Error(), "at least 8") {
+		t.Errorf("Expected minimum length error\ngot '%v'", err)
+	}
+}
+
+func TestBreachListHashes(t *testing.T) {
+	breached, err := ParseBreachList(strings.NewReader("2F9E53523B62ABC141A2B4D6019D23CBA835DBD0\n"))
+	if err != nil {
+		t.Fatalf("Couldn't parse breach list: %s", err)
+	}
+
+	if !breached.Contains("correct horse") {
+		t.Errorf("Expected hashed entry to match")
+	}
+	if breached.Contains("Correct Horse") {
+		t.Errorf("Expected hashed entries to be case sensitive")
+	}
+}
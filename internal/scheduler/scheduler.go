@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+const DefaultInterval = 15 * time.Second
+
+// Scheduler publishes scheduled chirps once their publish time has passed and deletes
+// accounts once their deletion grace period is over
+type Scheduler struct {
+	DB       *models.DB
+	Interval time.Duration
+}
+
+// Run checks for due chirps and accounts every Interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.publishDueChirps(now)
+			s.purgeDueUsers(now)
+		}
+	}
+}
+
+func (s *Scheduler) publishDueChirps(now time.Time) {
+	published, err := s.DB.PublishDueChirps(now)
+	if err != nil {
+		log.Printf("Could not publish scheduled chirps: %s", err)
+		return
+	}
+	if published > 0 {
+		log.Printf("Published %d scheduled chirps", published)
+	}
+}
+
+func (s *Scheduler) purgeDueUsers(now time.Time) {
+	purged, err := s.DB.PurgeDueUsers(now)
+	if err != nil {
+		log.Printf("Could not delete accounts: %s", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Deleted %d accounts", purged)
+	}
+}
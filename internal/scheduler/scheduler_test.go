@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheSeaGiraffe/web_server_demo/internal/models"
+)
+
+func TestSchedulerRun(t *testing.T) {
+	db, err := models.NewDB(filepath.Join(t.TempDir(), "chirp_db-scheduler.json"))
+	if err != nil {
+		t.Fatalf("could not establish database connection: %v", err)
+	}
+
+	publishAt := time.Now().UTC().Add(50 * time.Millisecond)
+	chirp, err := db.CreateChirp(models.Chirp{
+		Body:      "later",
+		AuthorID:  1,
+		Status:    models.ChirpScheduled,
+		PublishAt: &publishAt,
+	})
+	if err != nil {
+		t.Fatalf("could not create chirp: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s := Scheduler{DB: db, Interval: 10 * time.Millisecond}
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		chirp, err = db.GetChirpByID(chirp.ID)
+		if err != nil {
+			t.Fatalf("could not get chirp: %v", err)
+		}
+		if chirp.Status == models.ChirpPublished {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected '%v'\ngot '%v'", models.ChirpPublished, chirp.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Scheduler didn't stop after the context was cancelled")
+	}
+}
@@ -0,0 +1,259 @@
+package thumbnail
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultCacheDir is where thumbnails are cached unless told otherwise. It is outside
+// the working directory so the cache can't be read through the /app/ file server.
+var DefaultCacheDir = filepath.Join(os.TempDir(), "chirpy-thumbnails")
+
+// Sizes are the widths (in pixels) that can be requested. Restricting the sizes keeps
+// clients from filling the cache with arbitrary variants of the same image.
+var Sizes = []int{64, 128, 256, 512}
+
+var (
+	ErrUnsupportedSize   = errors.New("Unsupported thumbnail size")
+	ErrUnsupportedFormat = errors.New("Unsupported image format")
+	ErrBusy              = errors.New("Too many thumbnails are being generated")
+)
+
+var supportedExts = map[string]string{
+	".png":  "png",
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+}
+
+type job struct {
+	srcPath   string
+	cachePath string
+	width     int
+}
+
+// Service generates resized copies of the images under root and caches them on disk.
+// Generation is done by a pool of background workers so that a burst of requests for
+// the same image only results in a single resize.
+type Service struct {
+	root     string
+	cacheDir string
+	jobs     chan job
+
+	mu      sync.Mutex
+	waiting map[string][]chan error
+}
+
+// NewService creates a thumbnail service for the files under root and creates the cache
+// directory if it doesn't exist
+func NewService(root, cacheDir string) (*Service, error) {
+	err := os.MkdirAll(cacheDir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("could not create thumbnail cache dir: %w", err)
+	}
+
+	return &Service{
+		root:     root,
+		cacheDir: cacheDir,
+		jobs:     make(chan job, 64),
+		waiting:  make(map[string][]chan error),
+	}, nil
+}
+
+// Start launches n workers that process thumbnail jobs until ctx is cancelled
+func (s *Service) Start(ctx context.Context, n int) {
+	for range n {
+		go s.worker(ctx)
+	}
+}
+
+func (s *Service) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.jobs:
+			err := s.generate(j)
+			if err != nil {
+				log.Printf("Could not generate thumbnail for %s: %s", j.srcPath, err)
+			}
+			s.finish(j.cachePath, err)
+		}
+	}
+}
+
+// Get returns the path to a cached thumbnail of the image at urlPath (relative to the
+// service root) with the given width. If the thumbnail isn't cached yet it is queued
+// for generation and Get waits until a worker has written it.
+func (s *Service) Get(ctx context.Context, urlPath string, width int) (string, error) {
+	if !slices.Contains(Sizes, width) {
+		return "", ErrUnsupportedSize
+	}
+
+	srcPath := filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+urlPath)))
+	ext := strings.ToLower(filepath.Ext(srcPath))
+	if _, ok := supportedExts[ext]; !ok {
+		return "", ErrUnsupportedFormat
+	}
+
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return "", err
+	}
+	if srcInfo.IsDir() {
+		return "", os.ErrNotExist
+	}
+
+	cachePath := s.cachePath(srcPath, srcInfo, width, ext)
+	if _, err := os.Stat(cachePath); err == nil {
+		return cachePath, nil
+	}
+
+	done := s.enqueue(job{srcPath: srcPath, cachePath: cachePath, width: width})
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", err
+		}
+		return cachePath, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// cachePath derives the name of the cached file from the source path and its
+// modification time so that replacing an image invalidates its old thumbnails
+func (s *Service) cachePath(srcPath string, srcInfo os.FileInfo, width int, ext string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", srcPath, srcInfo.ModTime().UnixNano())))
+	name := fmt.Sprintf("%s_w%d%s", hex.EncodeToString(sum[:8]), width, ext)
+	return filepath.Join(s.cacheDir, name)
+}
+
+// enqueue registers a waiter for the job and hands it to the workers unless the same
+// thumbnail is already being generated. The job fails with ErrBusy if the queue is full
+// rather than waiting for a worker that may never come.
+func (s *Service) enqueue(j job) <-chan error {
+	done := make(chan error, 1)
+
+	s.mu.Lock()
+	waiters, inFlight := s.waiting[j.cachePath]
+	s.waiting[j.cachePath] = append(waiters, done)
+	s.mu.Unlock()
+
+	if !inFlight {
+		select {
+		case s.jobs <- j:
+		default:
+			s.finish(j.cachePath, ErrBusy)
+		}
+	}
+
+	return done
+}
+
+func (s *Service) finish(cachePath string, err error) {
+	s.mu.Lock()
+	waiters := s.waiting[cachePath]
+	delete(s.waiting, cachePath)
+	s.mu.Unlock()
+
+	for _, done := range waiters {
+		done <- err
+	}
+}
+
+func (s *Service) generate(j job) error {
+	srcFile, err := os.Open(j.srcPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	src, format, err := image.Decode(srcFile)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, err)
+	}
+
+	thumb := Resize(src, j.width)
+
+	// Write to a temporary file first so that readers never see a partial thumbnail
+	tmpFile, err := os.CreateTemp(s.cacheDir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(tmpFile, thumb, &jpeg.Options{Quality: 85})
+	default:
+		err = png.Encode(tmpFile, thumb)
+	}
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	err = tmpFile.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), j.cachePath)
+}
+
+// Resize scales src down to the given width while preserving the aspect ratio. Each
+// destination pixel is the average of the source pixels it covers. Images narrower than
+// width are not scaled up.
+func Resize(src image.Image, width int) *image.RGBA {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if width > srcW {
+		width = srcW
+	}
+	height := max(1, srcH*width/max(1, srcW))
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0 := bounds.Min.Y + y*srcH/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/height)
+		for x := range width {
+			x0 := bounds.Min.X + x*srcW/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,97 @@
+package thumbnail
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResize(t *testing.T) {
+	cases := []struct {
+		name       string
+		srcW, srcH int
+		width      int
+		wantW      int
+		wantH      int
+	}{
+		{"Test downscale", 512, 256, 128, 128, 64},
+		{"Test no upscale", 100, 50, 256, 100, 50},
+		{"Test thin image", 1000, 1, 64, 64, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			src := image.NewRGBA(image.Rect(0, 0, c.srcW, c.srcH))
+			got := Resize(src, c.width).Bounds()
+			if got.Dx() != c.wantW || got.Dy() != c.wantH {
+				t.Errorf("Expected %dx%d\ngot %dx%d", c.wantW, c.wantH, got.Dx(), got.Dy())
+			}
+		})
+	}
+}
+
+func TestServiceGet(t *testing.T) {
+	root := t.TempDir()
+	src := image.NewRGBA(image.Rect(0, 0, 300, 150))
+	for y := range 150 {
+		for x := range 300 {
+			src.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	f, err := os.Create(filepath.Join(root, "red.png"))
+	if err != nil {
+		t.Fatalf("could not create test image: %v", err)
+	}
+	err = png.Encode(f, src)
+	f.Close()
+	if err != nil {
+		t.Fatalf("could not encode test image: %v", err)
+	}
+
+	svc, err := NewService(root, filepath.Join(root, "cache"))
+	if err != nil {
+		t.Fatalf("could not create service: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	svc.Start(ctx, 1)
+
+	t.Run("Generates thumbnail", func(t *testing.T) {
+		thumbPath, err := svc.Get(ctx, "/red.png", 64)
+		if err != nil {
+			t.Fatalf("could not get thumbnail: %v", err)
+		}
+		thumbFile, err := os.Open(thumbPath)
+		if err != nil {
+			t.Fatalf("could not open thumbnail: %v", err)
+		}
+		defer thumbFile.Close()
+		thumb, err := png.Decode(thumbFile)
+		if err != nil {
+			t.Fatalf("could not decode thumbnail: %v", err)
+		}
+		if thumb.Bounds().Dx() != 64 || thumb.Bounds().Dy() != 32 {
+			t.Errorf("Expected 64x32\ngot %v", thumb.Bounds())
+		}
+	})
+
+	t.Run("Rejects unsupported size", func(t *testing.T) {
+		_, err := svc.Get(ctx, "/red.png", 65)
+		if !errors.Is(err, ErrUnsupportedSize) {
+			t.Errorf("Expected ErrUnsupportedSize\ngot %v", err)
+		}
+	})
+
+	t.Run("Does not escape root", func(t *testing.T) {
+		_, err := svc.Get(ctx, "/../../etc/passwd.png", 64)
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected os.ErrNotExist\ngot %v", err)
+		}
+	})
+}
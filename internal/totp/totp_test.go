@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA-1 test vectors from RFC 6238 appendix B, truncated to six digits
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, c := range cases {
+		got, err := Code(secret, Step(time.Unix(c.unix, 0)))
+		if err != nil {
+			t.Fatalf("Couldn't make code: %s", err)
+		}
+		if got != c.want {
+			t.Errorf("Time %d: expected '%v'\ngot '%v'", c.unix, c.want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("Couldn't generate secret: %s", err)
+	}
+
+	now := time.Now()
+	previous, _ := Code(secret, Step(now)-1)
+	tooOld, _ := Code(secret, Step(now)-2)
+
+	step, ok := Validate(secret, previous, now, 0)
+	if !ok || step != Step(now)-1 {
+		t.Errorf("Expected code from the previous period to be accepted")
+	}
+	if _, ok := Validate(secret, previous, now, step); ok {
+		t.Errorf("Expected a used code to be rejected")
+	}
+	if _, ok := Validate(secret, tooOld, now, 0); ok {
+		t.Errorf("Expected code from two periods ago to be rejected")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("JBSWY3DPEHPK3PXP", "Chirpy", "a@example.com")
+	want := "otpauth://totp/Chirpy:a@example.com?"
+	if !strings.HasPrefix(uri, want) || !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Errorf("Expected URI starting with '%v'\ngot '%v'", want, uri)
+	}
+}
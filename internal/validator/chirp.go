@@ -0,0 +1,139 @@
+package validator
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrChirpEmpty   = errors.New("Chirp is empty")
+	ErrChirpTooLong = errors.New("Chirp is too long")
+)
+
+// ChirpBody normalizes the body of a chirp and checks that it is neither empty nor
+// longer than maxLength user-perceived characters. The normalized body is returned so
+// that callers store exactly what was validated.
+func ChirpBody(body string, maxLength int) (string, error) {
+	body = NormalizeText(body)
+
+	if strings.TrimFunc(body, isBlank) == "" {
+		return "", ErrChirpEmpty
+	}
+
+	if CharCount(body) > maxLength {
+		return "", ErrChirpTooLong
+	}
+
+	return body, nil
+}
+
+// NormalizeText converts s to Unicode NFC and strips control characters other than
+// newlines and tabs. Bidirectional override characters are stripped as well since they
+// can be used to make text display differently from how it reads.
+func NormalizeText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n', r == '\t':
+			return r
+		case unicode.IsControl(r), isBidiControl(r):
+			return -1
+		default:
+			return r
+		}
+	}, s)
+
+	return norm.NFC.String(s)
+}
+
+// CharCount returns the number of user-perceived characters in s. This is an
+// approximation of the extended grapheme clusters from UAX #29 which covers combining
+// marks, emoji ZWJ sequences, skin tone and variation selectors, tag sequences and
+// regional indicator (flag) pairs. An extender with no character before it on the same
+// line counts as a character of its own, and a character made of more than
+// maxCharRunes runes counts again for every maxCharRunes, so invisible text can't be
+// used to get past length limits.
+func CharCount(s string) int {
+	count := 0
+	// Runes in the current character, 0 when there is nothing to extend
+	charRunes := 0
+	prevZWJ := false
+	pendingRegional := false
+	for _, r := range s {
+		joins := false
+		switch {
+		case prevZWJ:
+			// Character joined to the previous one
+			joins = true
+		case isExtender(r):
+			// Extends the previous character
+			joins = charRunes > 0
+		case isRegionalIndicator(r) && pendingRegional:
+			// Second half of a flag
+			joins = true
+		}
+		prevZWJ = false
+
+		if joins && charRunes < maxCharRunes {
+			charRunes++
+			pendingRegional = false
+		} else {
+			count++
+			charRunes = 1
+			pendingRegional = isRegionalIndicator(r)
+		}
+
+		// Line breaks and tabs aren't extended by what comes after them
+		if r == '\n' || r == '\t' {
+			charRunes = 0
+		}
+		if r == zeroWidthJoiner && charRunes > 0 {
+			prevZWJ = true
+		}
+	}
+
+	return count
+}
+
+// maxCharRunes is the most runes counted as a single character. It is well above the
+// longest emoji sequences, which are around a dozen runes.
+const maxCharRunes = 32
+
+const zeroWidthJoiner = '\u200d'
+
+func isExtender(r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r == zeroWidthJoiner:
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF:
+		// Variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		// Emoji skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F:
+		// Tag characters used by subdivision flags
+		return true
+	default:
+		return false
+	}
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func isBidiControl(r rune) bool {
+	return (r >= 0x202A && r <= 0x202E) || (r >= 0x2066 && r <= 0x2069)
+}
+
+// isBlank reports whether r is whitespace or an invisible character that on its own
+// would make a chirp look empty. Extenders such as combining marks and variation
+// selectors count as blank since there is nothing for them to attach to.
+func isBlank(r rune) bool {
+	return unicode.IsSpace(r) || r == '\u200b' || r == '\u200c' || r == '\u2060' || r == '\ufeff' || isExtender(r)
+}
@@ -0,0 +1,100 @@
+package validator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCharCount(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"Test ASCII", "hello", 5},
+		{"Test accented", "café", 4},
+		{"Test combining mark", "cafe\u0301", 4},
+		{"Test emoji", "\U0001F600\U0001F600", 2},
+		{"Test ZWJ family", "\U0001F468\u200d\U0001F469\u200d\U0001F467", 1},
+		{"Test skin tone", "\U0001F44D\U0001F3FD", 1},
+		{"Test flags", "\U0001F1EF\U0001F1F5\U0001F1FA\U0001F1F8", 2},
+		{"Test variation selector", "\u2764\ufe0f", 1},
+		{"Test leading combining mark", "\u0301a", 2},
+		{"Test leading variation selector", "\ufe0f\ufe0f", 1},
+		{"Test combining mark after newline", "a\n\u0301", 3},
+		{"Test zero-width joiners only", strings.Repeat("\u200d", 64), 2},
+		{"Test long run of tag characters", "a" + strings.Repeat("\U000E0061", 100), 4},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := CharCount(c.text)
+			if got != c.want {
+				t.Errorf("Expected %d\ngot %d", c.want, got)
+			}
+		})
+	}
+}
+
+func TestChirpBody(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Test NFC normalization",
+			body: "cafe\u0301",
+			want: "caf\u00e9",
+		},
+		{
+			name: "Test control characters stripped",
+			body: "hello\x00 \u202eworld\r\n",
+			want: "hello world\n",
+		},
+		{
+			name: "Test 140 emoji allowed",
+			body: strings.Repeat("\U0001F600", 140),
+			want: strings.Repeat("\U0001F600", 140),
+		},
+		{
+			name:    "Test too long",
+			body:    strings.Repeat("a", 141),
+			wantErr: ErrChirpTooLong,
+		},
+		{
+			name:    "Test empty",
+			body:    "",
+			wantErr: ErrChirpEmpty,
+		},
+		{
+			name:    "Test whitespace only",
+			body:    " \t\n\u200b\u3000",
+			wantErr: ErrChirpEmpty,
+		},
+		{
+			name:    "Test extenders only",
+			body:    "\u0301\ufe0f\u200d\U000E0061",
+			wantErr: ErrChirpEmpty,
+		},
+		{
+			name:    "Test too many extenders",
+			body:    "a" + strings.Repeat("\u0301", 141*maxCharRunes),
+			wantErr: ErrChirpTooLong,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ChirpBody(c.body, 140)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+			if got != c.want {
+				t.Errorf("Expected '%q'\ngot '%q'", c.want, got)
+			}
+		})
+	}
+}
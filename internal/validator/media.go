@@ -0,0 +1,82 @@
+package validator
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// MediaURLPrefix is where media hosted by this server lives. Chirps, drafts and avatars
+// can only point at images in this directory so that nothing else under the served
+// root, such as the database, can be passed off as media.
+const MediaURLPrefix = "/app/assets/"
+
+const (
+	maxMediaLength = 2048
+	// servedPrefix is the URL prefix of the served root
+	servedPrefix = "/app/"
+)
+
+var ErrMediaInvalid = errors.New("Media must be an http(s) URL or an image under /app/assets/")
+
+// mediaExts are the image types that can be served as media
+var mediaExts = map[string]struct{}{
+	".png":  {},
+	".jpg":  {},
+	".jpeg": {},
+	".gif":  {},
+	".webp": {},
+}
+
+// LocalMediaPath returns the path of media hosted by this server relative to the served
+// root, such as "assets/logo.png". ok is false for media hosted elsewhere and for
+// anything that isn't an image in the media directory.
+func LocalMediaPath(media string) (string, bool) {
+	u, err := url.Parse(media)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", false
+	}
+
+	p := u.Path
+	switch {
+	case !strings.HasPrefix(p, MediaURLPrefix), path.Clean(p) != p, strings.Contains(p, "\\"):
+		return "", false
+	}
+	if _, ok := mediaExts[strings.ToLower(path.Ext(p))]; !ok {
+		return "", false
+	}
+
+	return strings.TrimPrefix(p, servedPrefix), true
+}
+
+// Media checks that a media attachment is either an absolute http(s) URL or an image
+// in the media directory
+func Media(media string) (string, error) {
+	media = strings.TrimSpace(media)
+	if media == "" || len(media) > maxMediaLength {
+		return "", ErrMediaInvalid
+	}
+	if _, ok := LocalMediaPath(media); ok {
+		return media, nil
+	}
+
+	u, err := url.Parse(media)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return media, nil
+	}
+	return "", ErrMediaInvalid
+}
+
+// MediaList checks every attachment with Media and returns the cleaned up list
+func MediaList(media []string) ([]string, error) {
+	var cleaned []string
+	for _, m := range media {
+		m, err := Media(m)
+		if err != nil {
+			return nil, err
+		}
+		cleaned = append(cleaned, m)
+	}
+	return cleaned, nil
+}
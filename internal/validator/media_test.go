@@ -0,0 +1,36 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMedia(t *testing.T) {
+	cases := []struct {
+		name    string
+		media   string
+		wantErr error
+	}{
+		{"Test https URL", "https://example.com/cat.gif", nil},
+		{"Test image in media directory", "/app/assets/logo.png", nil},
+		{"Test image outside media directory", "/app/logo.png", ErrMediaInvalid},
+		{"Test database", "/app/chirp_db.json", ErrMediaInvalid},
+		{"Test env file", "/app/.env", ErrMediaInvalid},
+		{"Test non-image in media directory", "/app/assets/notes.txt", ErrMediaInvalid},
+		{"Test path traversal", "/app/assets/../chirp_db.png", ErrMediaInvalid},
+		{"Test encoded path traversal", "/app/assets/%2e%2e/chirp_db.png", ErrMediaInvalid},
+		{"Test query string", "/app/assets/logo.png?w=64", ErrMediaInvalid},
+		{"Test relative path", "assets/logo.png", ErrMediaInvalid},
+		{"Test file URL", "file:///etc/passwd", ErrMediaInvalid},
+		{"Test empty", "", ErrMediaInvalid},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Media(c.media)
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+		})
+	}
+}
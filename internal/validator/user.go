@@ -0,0 +1,81 @@
+package validator
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	MinHandleLength = 3
+	MaxHandleLength = 15
+)
+
+var (
+	ErrHandleLength   = errors.New("Handle must be between 3 and 15 characters long")
+	ErrHandleChars    = errors.New("Handle can only contain letters, numbers and underscores")
+	ErrHandleReserved = errors.New("Handle is reserved")
+	ErrHandleNumeric  = errors.New("Handle must contain at least one letter")
+	ErrAvatarInvalid  = errors.New("Avatar must be an http(s) URL or an image under /app/assets/")
+)
+
+// reservedHandles can't be taken by anyone since they would clash with routes or could
+// be used to impersonate the site
+var reservedHandles = map[string]struct{}{
+	"me":        {},
+	"admin":     {},
+	"api":       {},
+	"app":       {},
+	"chirpy":    {},
+	"help":      {},
+	"login":     {},
+	"moderator": {},
+	"root":      {},
+	"settings":  {},
+	"support":   {},
+	"system":    {},
+}
+
+// Handle normalizes a handle and checks that it is valid. A leading "@" is dropped and
+// handles are lowercased so that they are unique regardless of case. Handles need at
+// least one letter so that they can't be mistaken for user IDs.
+func Handle(handle string) (string, error) {
+	handle = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+
+	if len(handle) < MinHandleLength || len(handle) > MaxHandleLength {
+		return "", ErrHandleLength
+	}
+
+	hasLetter := false
+	for _, r := range handle {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '_' {
+			return "", ErrHandleChars
+		}
+		hasLetter = hasLetter || (r >= 'a' && r <= 'z')
+	}
+
+	// Handles that look like user IDs would be ambiguous in /api/users/{user}
+	if !hasLetter {
+		return "", ErrHandleNumeric
+	}
+
+	if _, ok := reservedHandles[handle]; ok {
+		return "", ErrHandleReserved
+	}
+
+	return handle, nil
+}
+
+// Avatar checks that an avatar is either empty or valid media, meaning an absolute
+// http(s) URL or an image under /app/assets/
+func Avatar(avatar string) (string, error) {
+	avatar = strings.TrimSpace(avatar)
+	if avatar == "" {
+		return "", nil
+	}
+
+	avatar, err := Media(avatar)
+	if err != nil {
+		return "", ErrAvatarInvalid
+	}
+	return avatar, nil
+}
@@ -0,0 +1,62 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHandle(t *testing.T) {
+	cases := []struct {
+		name    string
+		handle  string
+		want    string
+		wantErr error
+	}{
+		{"Test lowercased", "Chirpy_Fan", "chirpy_fan", nil},
+		{"Test leading @ dropped", "@bird42", "bird42", nil},
+		{"Test too short", "ab", "", ErrHandleLength},
+		{"Test too long", "abcdefghijklmnop", "", ErrHandleLength},
+		{"Test invalid characters", "bird.watcher", "", ErrHandleChars},
+		{"Test non-ASCII", "cafés", "", ErrHandleChars},
+		{"Test reserved", "Admin", "", ErrHandleReserved},
+		{"Test digits only", "12345", "", ErrHandleNumeric},
+		{"Test underscores only", "___", "", ErrHandleNumeric},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Handle(c.handle)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+			if got != c.want {
+				t.Errorf("Expected '%s'\ngot '%s'", c.want, got)
+			}
+		})
+	}
+}
+
+func TestAvatar(t *testing.T) {
+	cases := []struct {
+		name    string
+		avatar  string
+		wantErr error
+	}{
+		{"Test empty", "", nil},
+		{"Test https URL", "https://example.com/avatar.png", nil},
+		{"Test app path", "/app/assets/logo.png", nil},
+		{"Test other path", "/api/users", ErrAvatarInvalid},
+		{"Test path traversal", "/app/../chirp_db.json", ErrAvatarInvalid},
+		{"Test env file", "/app/.env", ErrAvatarInvalid},
+		{"Test javascript URL", "javascript:alert(1)", ErrAvatarInvalid},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Avatar(c.avatar)
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("Expected error '%v'\ngot '%v'", c.wantErr, err)
+			}
+		})
+	}
+}